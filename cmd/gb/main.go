@@ -1,85 +1,499 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
-	"github.com/worblehat/Gameboy-Emulator/internal/gb"
+	"github.com/worblehat/Gameboy-Emulator/gb"
 )
 
+var illegalOpcodePolicies = map[string]gb.IllegalOpcodePolicy{
+	"lockup": gb.IllegalOpcodeLockUp,
+	"error":  gb.IllegalOpcodeError,
+	"break":  gb.IllegalOpcodeBreak,
+}
+
 func main() {
-	bootROMPath := flag.String("boot-rom", "", "Path to a file with the boot ROM.")
+	bootROMPath := flag.String("boot-rom", "", "Path to a file with the boot ROM. Skips the boot sequence if omitted.")
 	cartROMPath := flag.String("cartridge-rom", "", "Path to a file with a cartridge ROM.")
 	withDebugger := flag.Bool("debug", false, "Enable debuger.")
+	gdbAddr := flag.String("gdb", "",
+		"Address like :2159 to serve the GDB remote protocol on (:PORT only listens on localhost). The emulation waits for GDB to connect.")
+	dapAddr := flag.String("dap", "",
+		"Serve the Debug Adapter Protocol on stdio (-) or an address like :4711 (:PORT only listens on localhost). "+
+			"The emulation waits for the configuration of the editor. "+
+			"Breakpoints in source files can only be set in lines with a label of the symbol file (-symbols).")
 	withTrace := flag.Bool("trace", false, "Print instructions on stdout as they are executed.")
+	illegalOpcode := flag.String("illegal-opcode", "lockup",
+		"What to do on an illegal op code: lockup (like the hardware), error or break (into the debugger).")
+	display := flag.String("display", "none", "Video output: none or terminal (ANSI colors with half-block characters).")
+	scale := flag.Int("scale", 1, "Integer scale factor of the video output and screenshots.")
+	paletteName := flag.String("palette", "dmg",
+		"Colors of the video output and screenshots: dmg, grey or four colors like #E0F8D0,#88C070,#346856,#081820.")
+	screenshotPath := flag.String("screenshot", "", "Path of a PNG file to save a screenshot to.")
+	screenshotFrame := flag.Uint("screenshot-at-frame", 1, "Number of the frame after which the screenshot is taken (from 1).")
+	videoPath := flag.String("record-video", "", "Path of an animated GIF (.gif) or APNG (.png) file to record the gameplay to.")
+	videoEvery := flag.Uint("record-every", 1, "Record every Nth frame.")
+	videoStart := flag.Uint("record-start", 0, "Number of the first frame to record.")
+	videoStop := flag.Uint("record-stop", 0, "Number of the last frame to record (0: until exit).")
+	videoPaused := flag.Bool("record-paused", false, "Start with the recording paused. It can be toggled with the debugger command \"record\".")
+	serialOut := flag.String("serial-out", "", "Path of a file to write the serial port output to (- for stdout).")
+	untilFrame := flag.Uint64("until-frame", 0, "Run without display until the given frame is reached.")
+	untilCycle := flag.Uint64("until-cycle", 0, "Run without display until the given clock cycle is reached.")
+	untilPC := flag.String("until-pc", "", "Run without display until PC reaches the given (hex) address.")
+	untilSerial := flag.String("until-serial", "", "Run without display until the given (hex) byte is sent over the serial port.")
+	untilMem := flag.String("until-mem", "", "Run without display until memory matches, e.g. C000=80 (hex).")
+	timeoutFrames := flag.Uint64("timeout-frames", 0,
+		fmt.Sprintf("Exit with code %v if no -until-* condition is met within the given number of frames.", exitTimeout))
+	dumpScreenshot := flag.String("dump-screenshot", "", "Path of a PNG file to save a screenshot to at the end of an -until-* run.")
+	dumpRegisters := flag.String("dump-registers", "", "Path of a file to write the registers to at the end of an -until-* run.")
+	dumpMem := flag.String("dump-mem", "", "Path of a file to write the memory range given by -dump-mem-range to at the end of an -until-* run.")
+	dumpMemRange := flag.String("dump-mem-range", "C000:2000", "Memory range for -dump-mem as START:LENGTH (hex).")
+	statePrefix := flag.String("state-prefix", "",
+		"Path prefix of the save state slot files PREFIX.ss0 to PREFIX.ss9 (default: cartridge ROM path without extension).")
+	loadState := flag.Int("load-state", -1, "Load the save state from the given slot (0-9) at startup.")
+	rewindMem := flag.Int("rewind-mem", 32,
+		"Memory in MiB for the rewind history of the terminal display (key r) and the debugger (reverse-step). 0 disables rewinding.")
+	rewindEvery := flag.Uint("rewind-every", 1, "Take a rewind snapshot every Nth frame.")
+	recordMovie := flag.String("record-movie", "", "Path of a movie file to record the input of every frame to.")
+	playMovie := flag.String("play-movie", "", "Path of a movie file to play back.")
+	symbolsPath := flag.String("symbols", "", "Path of an RGBDS symbol file (bank:addr Label) for the debugger and trace.")
+	mapPath := flag.String("map", "", "Path of an RGBDS map file with more symbols for the debugger and trace.")
+	logSpec := flag.String("log", "",
+		"Log levels per category, e.g. io=debug,cart=info (categories: cpu, mem, io, ppu, apu, cart; "+
+			"levels: off, error, warn, info, debug, trace).")
 	flag.Parse()
 
-	if *bootROMPath == "" {
-		fmt.Println("Error: No boot ROM file provided on command line")
+	if *cartROMPath == "" {
+		fmt.Println("Error: No cartridge ROM file provided on command line")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
-	if *cartROMPath == "" {
-		fmt.Println("Error: No cartridge ROM file provided on command line")
+
+	if *display != "none" && *display != "terminal" {
+		fmt.Printf("Error: Unknown display %v\n", *display)
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
+	if *display == "terminal" && *withDebugger {
+		fmt.Println("Error: The debugger can not be used with the terminal display")
+		os.Exit(1)
+	}
+	frontends := 0
+	for _, enabled := range []bool{*withDebugger, *gdbAddr != "", *dapAddr != ""} {
+		if enabled {
+			frontends += 1
+		}
+	}
+	if frontends > 1 {
+		fmt.Println("Error: Only one of -debug, -gdb and -dap can be used")
+		os.Exit(1)
+	}
+	if *display == "terminal" && *dapAddr == "-" {
+		fmt.Println("Error: The Debug Adapter Protocol on stdio can not be used with the terminal display")
+		os.Exit(1)
+	}
+	if *screenshotPath != "" && *screenshotFrame == 0 {
+		fmt.Println("Error: -screenshot-at-frame must be at least 1")
+		os.Exit(1)
+	}
+	// Interactive sessions do not end when the requested output is written.
+	interactive := frontends > 0
 
-	bootROM, err := loadBootROM(*bootROMPath)
+	palette, err := gb.ParsePalette(*paletteName)
 	if err != nil {
-		fmt.Printf("Error: Could not load boot ROM from file %v (%v)\n", *bootROMPath, err)
-		os.Exit(2)
+		fmt.Printf("Error: %v\n", err)
+		flag.PrintDefaults()
+		os.Exit(1)
 	}
 
-	cartROM0, err := loadCartROM0(*cartROMPath)
+	cond := &stopConditions{
+		frame:         *untilFrame,
+		cycle:         *untilCycle,
+		pc:            -1,
+		serial:        -1,
+		timeoutFrames: *timeoutFrames,
+	}
+	if *untilPC != "" {
+		pc, err := parseHex(*untilPC, 16)
+		if err != nil {
+			fmt.Printf("Error: Invalid -until-pc address %v\n", *untilPC)
+			os.Exit(1)
+		}
+		cond.pc = int(pc)
+	}
+	if *untilSerial != "" {
+		b, err := parseHex(*untilSerial, 8)
+		if err != nil {
+			fmt.Printf("Error: Invalid -until-serial byte %v\n", *untilSerial)
+			os.Exit(1)
+		}
+		cond.serial = int(b)
+	}
+	if *untilMem != "" {
+		if cond.mem, err = parseMemCondition(*untilMem); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	out := &dumps{
+		screenshot: *dumpScreenshot,
+		registers:  *dumpRegisters,
+		mem:        *dumpMem,
+	}
+	if out.memStart, out.memLen, err = parseMemRange(*dumpMemRange); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if cond.any() && (*display != "none" || interactive) {
+		fmt.Println("Error: -until-* conditions can only be used without display and debugger")
+		os.Exit(1)
+	}
+
+	if *loadState >= stateSlotCount {
+		fmt.Printf("Error: Invalid save state slot %v\n", *loadState)
+		os.Exit(1)
+	}
+
+	policy, ok := illegalOpcodePolicies[*illegalOpcode]
+	if !ok {
+		fmt.Printf("Error: Unknown illegal op code policy %v\n", *illegalOpcode)
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	logger := gb.NewLevelLogger(os.Stderr)
+	if err := logger.Configure(*logSpec); err != nil {
+		fmt.Printf("Error: Invalid -log option (%v)\n", err)
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var bootROM []byte
+	if *bootROMPath != "" {
+		bootROM, err = os.ReadFile(*bootROMPath)
+		if err != nil {
+			fmt.Printf("Error: Could not load boot ROM from file %v (%v)\n", *bootROMPath, err)
+			os.Exit(2)
+		}
+	}
+
+	cartROM, err := os.ReadFile(*cartROMPath)
 	if err != nil {
 		fmt.Printf("Error: Could not load cartridge ROM from file %v (%v)\n", *cartROMPath, err)
 		os.Exit(3)
 	}
 
-	memory := gb.NewMemory(bootROM, cartROM0)
+	emu, err := gb.NewEmulator(bootROM, cartROM)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(3)
+	}
+	emu.SetLogger(logger)
+	emu.EnableDebugger(*withDebugger)
+	emu.EnableTrace(*withTrace)
+	emu.SetIllegalOpcodePolicy(policy)
+	if *symbolsPath != "" || *mapPath != "" {
+		symbols, err := loadSymbols(*symbolsPath, *mapPath)
+		if err != nil {
+			fmt.Printf("Error: Could not load symbols (%v)\n", err)
+			os.Exit(1)
+		}
+		emu.SetSymbols(symbols)
+	}
+	if *gdbAddr != "" {
+		listener, err := listenLocal(*gdbAddr)
+		if err != nil {
+			fmt.Printf("Error: Could not listen for GDB (%v)\n", err)
+			os.Exit(1)
+		}
+		defer listener.Close()
+		emu.ServeGDB(listener)
+	}
+	if *dapAddr == "-" {
+		// The protocol owns stdout, all other output goes to stderr.
+		dapOut := os.Stdout
+		os.Stdout = os.Stderr
+		emu.ServeDAP(os.Stdin, dapOut)
+	} else if *dapAddr != "" {
+		listener, err := listenLocal(*dapAddr)
+		if err != nil {
+			fmt.Printf("Error: Could not listen for the editor (%v)\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Waiting for the editor to connect to %v\n", listener.Addr())
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+		emu.ServeDAP(conn, conn)
+	}
+	emu.Debugger().Palette = palette
+	emu.Debugger().Scale = *scale
 
-	cpu := gb.NewCPU(memory)
-	cpu.Run(*withDebugger, *withTrace)
-}
+	slots := &stateSlots{prefix: *statePrefix}
+	if slots.prefix == "" {
+		slots.prefix = defaultStatePrefix(*cartROMPath)
+	}
+	if *loadState >= 0 {
+		slots.current = *loadState
+		if err := slots.load(emu, *loadState); err != nil {
+			fmt.Printf("Error: Could not load save state (%v)\n", err)
+			os.Exit(3)
+		}
+	}
 
-func loadBootROM(romPath string) ([gb.BootROMSize]byte, error) {
-	var rom [gb.BootROMSize]byte
+	if *recordMovie != "" && *playMovie != "" {
+		fmt.Println("Error: -record-movie and -play-movie can not be used together")
+		os.Exit(1)
+	}
+	var movie *gb.Movie
+	if *playMovie != "" {
+		movie, err = loadMovie(*playMovie)
+		if err == nil {
+			err = emu.PlayMovie(movie)
+		}
+		if err != nil {
+			fmt.Printf("Error: Could not play movie (%v)\n", err)
+			os.Exit(3)
+		}
+	}
+	if *recordMovie != "" {
+		if movie, err = emu.RecordMovie(time.Now().Unix()); err != nil {
+			fmt.Printf("Error: Could not record movie (%v)\n", err)
+			os.Exit(3)
+		}
+	}
 
-	content, err := os.ReadFile(romPath)
-	if err != nil {
-		return rom, err
+	// Only interactive sessions can rewind, so runs without display do not
+	// pay for the snapshots.
+	rewinding := *rewindMem > 0 && (*display == "terminal" || *withDebugger)
+	if rewinding {
+		emu.SetRewinder(gb.NewRewinder(*rewindEvery, *rewindMem<<20))
+	}
+
+	var serialWriters []io.Writer
+	if *serialOut == "-" {
+		serialWriters = append(serialWriters, os.Stdout)
+	} else if *serialOut != "" {
+		file, err := os.Create(*serialOut)
+		if err != nil {
+			fmt.Printf("Error: Could not create serial output file (%v)\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		serialWriters = append(serialWriters, file)
+	}
+	if cond.serial >= 0 {
+		serialWriters = append(serialWriters, cond)
+	}
+	if len(serialWriters) > 0 {
+		emu.SetSerialOutput(io.MultiWriter(serialWriters...))
+	}
+
+	var recorder *gb.Recorder
+	if *videoPath != "" {
+		format, err := gb.RecordingFormatFromPath(*videoPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		recorder = gb.NewRecorder(format, palette, *scale, *videoEvery)
+		recorder.First = uint64(*videoStart)
+		recorder.Last = uint64(*videoStop)
+		recorder.Active = !*videoPaused
+		emu.SetRecorder(recorder)
+	}
+
+	// Finish the recordings on Ctrl+C instead of losing them.
+	interrupt := make(chan os.Signal, 1)
+	if recorder != nil || *recordMovie != "" {
+		signal.Notify(interrupt, os.Interrupt)
+	}
+
+	// Without display or debugger there is nothing left to look at once
+	// the requested output is written.
+	headless := *display == "none" && !interactive
+	afterFrame := func() (bool, error) {
+		frame := uint(emu.Frames())
+		select {
+		case <-interrupt:
+			return true, nil
+		default:
+		}
+		if *screenshotPath != "" && frame == *screenshotFrame {
+			if err := saveScreenshot(emu, *screenshotPath, palette, *scale); err != nil {
+				return true, err
+			}
+			if headless && recorder == nil {
+				return true, nil
+			}
+		}
+		if headless && *playMovie != "" && !emu.MoviePlaying() {
+			fmt.Printf("Movie ended at frame %v\n", frame)
+			return true, nil
+		}
+		if recorder != nil && recorder.Done(uint64(frame)) {
+			emu.SetRecorder(nil)
+			err := saveRecording(recorder, *videoPath)
+			recorder = nil
+			return headless || err != nil, err
+		}
+		return false, nil
+	}
+	// finish writes outputs that are still pending when the emulation ends.
+	finish := func() {
+		if *recordMovie != "" {
+			if err := saveMovie(movie, *recordMovie); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+		}
+		if recorder != nil {
+			if err := saveRecording(recorder, *videoPath); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+		}
 	}
 
-	if len(content) > len(rom) {
-		return rom, fmt.Errorf(
-			"provided ROM file of size %vB is too large for boot ROM", len(content))
+	if cond.any() {
+		code, err := runUntil(emu, cond, afterFrame)
+		finish()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(4)
+		}
+		if err := out.write(emu, palette, *scale); err != nil {
+			fmt.Printf("Error: Could not write dumps (%v)\n", err)
+			os.Exit(4)
+		}
+		os.Exit(code)
 	}
 
-	copy(rom[:], content[:gb.BootROMSize])
+	if *display == "terminal" {
+		term, err := newTerminalDisplay(palette, *scale, slots)
+		if err != nil {
+			fmt.Printf("Error: Could not set up terminal display (%v)\n", err)
+			os.Exit(5)
+		}
+		term.rewinding = rewinding
+		err = term.run(emu, afterFrame)
+		term.close()
+		finish()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(4)
+		}
+		return
+	}
 
-	return rom, nil
+	for {
+		if err := emu.RunFrame(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(4)
+		}
+		stop, err := afterFrame()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(4)
+		}
+		if stop {
+			finish()
+			return
+		}
+	}
 }
 
-func loadCartROM0(romPath string) ([gb.CartROM0Size]byte, error) {
-	var rom [gb.CartROM0Size]byte
+func saveRecording(recorder *gb.Recorder, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := recorder.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
-	file, err := os.Open(romPath)
+// loadSymbols loads the symbols of a symbol file and a map file. Either
+// path may be empty.
+func loadSymbols(symPath, mapPath string) (*gb.Symbols, error) {
+	var symbols *gb.Symbols
+	if symPath != "" {
+		file, err := os.Open(symPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if symbols, err = gb.ParseSymbols(file); err != nil {
+			return nil, err
+		}
+	}
+	if mapPath != "" {
+		file, err := os.Open(mapPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		mapped, err := gb.ParseMap(file)
+		if err != nil {
+			return nil, err
+		}
+		if symbols == nil {
+			return mapped, nil
+		}
+		symbols.Merge(mapped)
+	}
+	return symbols, nil
+}
+
+// listenLocal listens on a TCP address. Addresses without host only
+// listen on localhost.
+func listenLocal(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return net.Listen("tcp", addr)
+}
+
+func loadMovie(path string) (*gb.Movie, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		return rom, err
+		return nil, err
 	}
 	defer file.Close()
+	return gb.ReadMovie(file)
+}
 
-	n, err := file.Read(rom[:])
-
+func saveMovie(movie *gb.Movie, path string) error {
+	file, err := os.Create(path)
 	if err != nil {
-		return rom, err
+		return err
 	}
-	if n != len(rom) {
-		return rom, errors.New("provided cartridge ROM file is to small")
+	if err := movie.Write(file); err != nil {
+		file.Close()
+		return err
 	}
+	return file.Close()
+}
 
-	return rom, nil
+func saveScreenshot(emu *gb.Emulator, path string, palette gb.Palette, scale int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := emu.Framebuffer().WritePNG(file, palette, scale); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
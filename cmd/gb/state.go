@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/worblehat/Gameboy-Emulator/gb"
+)
+
+const stateSlotCount = 10
+
+// stateSlots stores save states in numbered slot files, e.g. game.ss0 to
+// game.ss9 for the prefix game.
+type stateSlots struct {
+	prefix  string
+	current int
+}
+
+// defaultStatePrefix returns the path of the cartridge ROM without extension.
+func defaultStatePrefix(cartROMPath string) string {
+	return strings.TrimSuffix(cartROMPath, filepath.Ext(cartROMPath))
+}
+
+func (s *stateSlots) path(slot int) string {
+	return fmt.Sprintf("%v.ss%d", s.prefix, slot)
+}
+
+// save writes the state of the emulator to the given slot.
+func (s *stateSlots) save(emu *gb.Emulator, slot int) error {
+	path := s.path(slot)
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := emu.SaveState(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// load restores the state of the emulator from the given slot.
+func (s *stateSlots) load(emu *gb.Emulator, slot int) error {
+	file, err := os.Open(s.path(slot))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return emu.LoadState(file)
+}
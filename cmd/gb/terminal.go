@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/worblehat/Gameboy-Emulator/gb"
+)
+
+// frameDuration is the time the DMG takes for one frame (about 16.74ms).
+const frameDuration = time.Second * gb.CyclesPerFrame / gb.ClockRate
+
+// keyHoldFrames is the number of frames a key counts as held after it has
+// been pressed. Terminals only report key presses (and repeats) but no releases.
+const keyHoldFrames = 8
+
+var terminalKeys = map[string]gb.Buttons{
+	"\x1b[A": gb.ButtonUp,
+	"\x1b[B": gb.ButtonDown,
+	"\x1b[C": gb.ButtonRight,
+	"\x1b[D": gb.ButtonLeft,
+	"x":      gb.ButtonA,
+	"z":      gb.ButtonB,
+	"\r":     gb.ButtonStart,
+	"\x7f":   gb.ButtonSelect,
+	" ":      gb.ButtonSelect,
+}
+
+const keyQuit = "q"
+const keyInterrupt = "\x03"
+
+// Keys for save states: 0-9 select the slot.
+const keySaveState = "s"
+const keyLoadState = "l"
+
+// keyRewind steps back one frame per frame while it is held.
+const keyRewind = "r"
+
+// terminalDisplay renders the framebuffer into a terminal with Unicode
+// half-block characters and reads the joypad input from the keyboard.
+type terminalDisplay struct {
+	out   *bufio.Writer
+	scale int
+	keys  chan string
+	// held counts down the frames each button is still held.
+	held map[gb.Buttons]int
+	// rewindHeld counts down the frames the rewind key is still held.
+	rewindHeld int
+	stty       string
+	// fgCodes and bgCodes are the escape codes for the colors of the palette.
+	fgCodes [4]string
+	bgCodes [4]string
+	fg      int
+	bg      int
+	slots   *stateSlots
+	// rewinding is set if the emulator keeps a history to rewind.
+	rewinding bool
+}
+
+func newTerminalDisplay(palette gb.Palette, scale int, slots *stateSlots) (*terminalDisplay, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("invalid scale %v", scale)
+	}
+	colorTerm := os.Getenv("COLORTERM")
+	trueColor := colorTerm == "truecolor" || colorTerm == "24bit"
+
+	t := &terminalDisplay{
+		out:   bufio.NewWriterSize(os.Stdout, 1<<16),
+		scale: scale,
+		keys:  make(chan string, 16),
+		held:  make(map[gb.Buttons]int),
+		slots: slots,
+	}
+	for i, c := range palette {
+		t.fgCodes[i] = colorCode(38, c.R, c.G, c.B, trueColor)
+		t.bgCodes[i] = colorCode(48, c.R, c.G, c.B, trueColor)
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("could not get terminal state (%v)", err)
+	}
+	t.stty = strings.TrimSpace(state)
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("could not enable raw mode (%v)", err)
+	}
+
+	// Hide the cursor and clear the screen.
+	fmt.Fprint(t.out, "\x1b[?25l\x1b[2J")
+	go t.readKeys()
+	return t, nil
+}
+
+// close restores the terminal state.
+func (t *terminalDisplay) close() {
+	fmt.Fprint(t.out, "\x1b[0m\x1b[?25h\r\n")
+	t.out.Flush()
+	stty(t.stty)
+}
+
+// run emulates and draws frames at the speed of the DMG until the user quits
+// or afterFrame requests to stop.
+func (t *terminalDisplay) run(emu *gb.Emulator, afterFrame func() (bool, error)) error {
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if quit := t.pollInput(emu); quit {
+			return nil
+		}
+		if t.rewindHeld > 0 {
+			// Stay at the oldest state when the history is exhausted.
+			if err := emu.StepBack(); err != nil && !errors.Is(err, gb.ErrNoHistory) {
+				return err
+			}
+			t.draw(emu.Framebuffer())
+			continue
+		}
+		if err := emu.RunFrame(); err != nil {
+			return err
+		}
+		t.draw(emu.Framebuffer())
+		if stop, err := afterFrame(); stop || err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollInput processes the pending key presses and updates the joypad.
+// It returns true if the user wants to quit.
+func (t *terminalDisplay) pollInput(emu *gb.Emulator) bool {
+	for button, frames := range t.held {
+		if frames <= 1 {
+			delete(t.held, button)
+		} else {
+			t.held[button] = frames - 1
+		}
+	}
+	if t.rewindHeld > 0 {
+		t.rewindHeld -= 1
+	}
+
+	for pending := true; pending; {
+		select {
+		case key := <-t.keys:
+			if key == keyQuit || key == keyInterrupt {
+				return true
+			}
+			if button, ok := terminalKeys[key]; ok {
+				t.held[button] = keyHoldFrames
+			}
+			if key == keyRewind && t.rewinding {
+				t.rewindHeld = keyHoldFrames
+			}
+			t.handleStateKey(emu, key)
+		default:
+			pending = false
+		}
+	}
+
+	var buttons gb.Buttons
+	for button := range t.held {
+		buttons |= button
+	}
+	emu.SetInput(buttons)
+	return false
+}
+
+// handleStateKey selects a slot, saves or loads a state and reports the
+// result in the window title.
+func (t *terminalDisplay) handleStateKey(emu *gb.Emulator, key string) {
+	switch {
+	case len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+		t.slots.current = int(key[0] - '0')
+		t.setTitle(fmt.Sprintf("Slot %v", t.slots.current))
+	case key == keySaveState:
+		if err := t.slots.save(emu, t.slots.current); err != nil {
+			t.setTitle(fmt.Sprintf("Could not save state: %v", err))
+		} else {
+			t.setTitle(fmt.Sprintf("Saved state to slot %v", t.slots.current))
+		}
+	case key == keyLoadState:
+		if err := t.slots.load(emu, t.slots.current); err != nil {
+			t.setTitle(fmt.Sprintf("Could not load state: %v", err))
+		} else {
+			t.setTitle(fmt.Sprintf("Loaded state from slot %v", t.slots.current))
+		}
+	}
+}
+
+// setTitle shows a message in the title of the terminal window.
+func (t *terminalDisplay) setTitle(msg string) {
+	fmt.Fprintf(t.out, "\x1b]0;%v\x07", msg)
+}
+
+// readKeys reads key presses from stdin and splits them into single keys
+// and escape sequences.
+func (t *terminalDisplay) readKeys() {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		input := string(buf[:n])
+		for len(input) > 0 {
+			size := 1
+			if strings.HasPrefix(input, "\x1b[") && len(input) >= 3 {
+				size = 3
+			}
+			t.keys <- input[:size]
+			input = input[size:]
+		}
+	}
+}
+
+// draw renders the framebuffer. Each character cell shows two vertically
+// adjacent pixels: the upper one as foreground of "▀", the lower one as background.
+func (t *terminalDisplay) draw(frame *gb.Framebuffer) {
+	t.fg, t.bg = -1, -1
+	fmt.Fprint(t.out, "\x1b[H")
+
+	rows := gb.ScreenHeight * t.scale
+	cols := gb.ScreenWidth * t.scale
+	for y := 0; y < rows; y += 2 {
+		for x := 0; x < cols; x += 1 {
+			upper := frame[y/t.scale][x/t.scale]
+			lower := upper
+			if y+1 < rows {
+				lower = frame[(y+1)/t.scale][x/t.scale]
+			}
+			t.setColors(int(upper&0x03), int(lower&0x03))
+			t.out.WriteString("▀")
+		}
+		t.out.WriteString("\x1b[0m\r\n")
+		t.fg, t.bg = -1, -1
+	}
+	t.out.Flush()
+}
+
+// setColors emits the escape codes for the given palette colors if they
+// differ from the current ones.
+func (t *terminalDisplay) setColors(fg, bg int) {
+	if fg != t.fg {
+		t.out.WriteString(t.fgCodes[fg])
+		t.fg = fg
+	}
+	if bg != t.bg {
+		t.out.WriteString(t.bgCodes[bg])
+		t.bg = bg
+	}
+}
+
+// colorCode returns the SGR escape code that sets a foreground (38) or
+// background (48) color, either as 24-bit color or from the 256 color palette.
+func colorCode(layer int, r, g, b uint8, trueColor bool) string {
+	if trueColor {
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, r, g, b)
+	}
+	// Nearest color of the 6x6x6 color cube (indices 16-231).
+	r6 := (int(r)*5 + 127) / 255
+	g6 := (int(g)*5 + 127) / 255
+	b6 := (int(b)*5 + 127) / 255
+	return fmt.Sprintf("\x1b[%d;5;%dm", layer, 16+36*r6+6*g6+b6)
+}
+
+// stty runs stty with the given arguments on the controlling terminal.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/worblehat/Gameboy-Emulator/gb"
+)
+
+// Exit codes of a run with stop conditions.
+const exitConditionMet = 0
+const exitTimeout = 6
+
+// stopConditions end a headless run as soon as one of them holds.
+type stopConditions struct {
+	frame  uint64
+	cycle  uint64
+	pc     int
+	serial int
+	mem    *memCondition
+	// timeoutFrames fails the run if no condition held within that many frames.
+	timeoutFrames uint64
+	serialSeen    bool
+}
+
+// memCondition holds when the byte at addr has the given value.
+type memCondition struct {
+	addr  uint16
+	value uint8
+}
+
+func (c *stopConditions) any() bool {
+	return c.frame != 0 || c.cycle != 0 || c.pc >= 0 || c.serial >= 0 || c.mem != nil
+}
+
+// Write receives the bytes sent over the serial port.
+func (c *stopConditions) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if int(b) == c.serial {
+			c.serialSeen = true
+		}
+	}
+	return len(p), nil
+}
+
+// met returns a description of the first condition that holds or "" if none does.
+func (c *stopConditions) met(emu *gb.Emulator) (string, error) {
+	if c.frame != 0 && emu.Frames() >= c.frame {
+		return fmt.Sprintf("frame %v reached", emu.Frames()), nil
+	}
+	if c.cycle != 0 && emu.Cycles() >= c.cycle {
+		return fmt.Sprintf("cycle %v reached", emu.Cycles()), nil
+	}
+	if c.pc >= 0 && int(emu.Registers().PC) == c.pc {
+		return fmt.Sprintf("PC reached 0x%04X", c.pc), nil
+	}
+	if c.serialSeen {
+		return fmt.Sprintf("0x%02X received on serial port", c.serial), nil
+	}
+	if c.mem != nil {
+		data, err := emu.ReadMemory(c.mem.addr, 1)
+		if err != nil {
+			return "", err
+		}
+		if data[0] == c.mem.value {
+			return fmt.Sprintf("memory at 0x%04X is 0x%02X", c.mem.addr, c.mem.value), nil
+		}
+	}
+	return "", nil
+}
+
+// runUntil runs the emulation instruction by instruction until a stop
+// condition holds or the timeout is reached and returns the exit code.
+func runUntil(emu *gb.Emulator, cond *stopConditions, afterFrame func() (bool, error)) (int, error) {
+	for {
+		frame := emu.Frames()
+		if err := emu.StepInstruction(); err != nil {
+			return 0, err
+		}
+		if emu.Frames() != frame {
+			if _, err := afterFrame(); err != nil {
+				return 0, err
+			}
+		}
+
+		reason, err := cond.met(emu)
+		if err != nil {
+			return 0, err
+		}
+		if reason != "" {
+			fmt.Printf("Stopped: %v\n", reason)
+			return exitConditionMet, nil
+		}
+		if cond.timeoutFrames != 0 && emu.Frames() >= cond.timeoutFrames {
+			fmt.Printf("Timeout: no condition met after %v frames\n", emu.Frames())
+			return exitTimeout, nil
+		}
+	}
+}
+
+// dumps are the files written at the end of a headless run.
+type dumps struct {
+	screenshot string
+	registers  string
+	mem        string
+	memStart   uint16
+	memLen     int
+}
+
+func (d *dumps) write(emu *gb.Emulator, palette gb.Palette, scale int) error {
+	if d.screenshot != "" {
+		if err := saveScreenshot(emu, d.screenshot, palette, scale); err != nil {
+			return err
+		}
+	}
+	if d.registers != "" {
+		reg := emu.Registers()
+		text := fmt.Sprintf("AF=%04X BC=%04X DE=%04X HL=%04X SP=%04X PC=%04X\n",
+			reg.AF(), reg.BC(), reg.DE(), reg.HL(), reg.SP, reg.PC)
+		if err := os.WriteFile(d.registers, []byte(text), 0644); err != nil {
+			return err
+		}
+	}
+	if d.mem != "" {
+		// Unmapped addresses read as 0xFF, which is fine for a dump.
+		data, _ := emu.ReadMemory(d.memStart, d.memLen)
+		if err := os.WriteFile(d.mem, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseHex parses a hexadecimal number with optional 0x or $ prefix.
+func parseHex(s string, bits int) (uint64, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "$")
+	return strconv.ParseUint(s, 16, bits)
+}
+
+// parseMemCondition parses a condition like C000=80.
+func parseMemCondition(s string) (*memCondition, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid memory condition %q (expected ADDR=VALUE)", s)
+	}
+	addr, err := parseHex(parts[0], 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address in memory condition %q", s)
+	}
+	value, err := parseHex(parts[1], 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value in memory condition %q", s)
+	}
+	return &memCondition{uint16(addr), uint8(value)}, nil
+}
+
+// parseMemRange parses a memory range like C000:100 (start and length in hex).
+func parseMemRange(s string) (uint16, int, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid memory range %q (expected START:LENGTH)", s)
+	}
+	start, err := parseHex(parts[0], 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start of memory range %q", s)
+	}
+	length, err := parseHex(parts[1], 32)
+	if err != nil || length > 0x10000 {
+		return 0, 0, fmt.Errorf("invalid length of memory range %q", s)
+	}
+	return uint16(start), int(length), nil
+}
@@ -0,0 +1,72 @@
+package gb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// blarggDir contains blargg's test ROMs, either directly (testdata/blargg/cpu_instrs.gb)
+// or in the directory layout of the original archive (testdata/blargg/cpu_instrs/cpu_instrs.gb).
+const blarggDir = "testdata/blargg"
+
+// blarggMaxFrames is the time (in frames) a ROM gets to print its result.
+// cpu_instrs needs almost a minute on real hardware.
+const blarggMaxFrames = 120 * 60
+
+var blarggROMs = []string{
+	"cpu_instrs",
+	"instr_timing",
+	"mem_timing",
+	"halt_bug",
+}
+
+// TestBlargg runs blargg's test ROMs and checks the result they print over
+// the serial port. ROMs that are not available locally are skipped.
+func TestBlargg(t *testing.T) {
+	for _, name := range blarggROMs {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			rom, err := loadBlarggROM(name)
+			if err != nil {
+				t.Skipf("ROM not available: %v", err)
+			}
+			runBlarggROM(t, rom)
+		})
+	}
+}
+
+func loadBlarggROM(name string) ([]byte, error) {
+	rom, err := os.ReadFile(filepath.Join(blarggDir, name+".gb"))
+	if err == nil {
+		return rom, nil
+	}
+	return os.ReadFile(filepath.Join(blarggDir, name, name+".gb"))
+}
+
+func runBlarggROM(t *testing.T, rom []byte) {
+	emu, err := NewEmulator(nil, rom)
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	emu.SetIllegalOpcodePolicy(IllegalOpcodeError)
+	var serial bytes.Buffer
+	emu.SetSerialOutput(&serial)
+
+	for emu.Frames() < blarggMaxFrames {
+		if err := emu.RunFrame(); err != nil {
+			t.Fatalf("Emulation failed: %v\nSerial output:\n%v", err, serial.String())
+		}
+		output := serial.String()
+		if strings.Contains(output, "Passed") {
+			t.Logf("Serial output:\n%v", output)
+			return
+		}
+		if strings.Contains(output, "Failed") {
+			t.Fatalf("Serial output:\n%v", output)
+		}
+	}
+	t.Fatalf("No result after %v frames\nSerial output:\n%v", blarggMaxFrames, serial.String())
+}
@@ -0,0 +1,33 @@
+package gb
+
+// Bus is the interface the CPU, the instructions and the debugger use to
+// access memory. Memory implements it with the memory map of the DMG but any
+// other implementation can be used, e.g. a flat RAM for testing single
+// instructions or a wrapper that records or instruments all accesses.
+type Bus interface {
+	Read8(addr uint16) uint8
+	Write8(addr uint16, val uint8)
+	Read16(addr uint16) uint16
+	Write16(addr uint16, val uint16)
+	// Tick is called by the CPU after each instruction with the number of
+	// clock cycles it took.
+	Tick(cycles uint)
+}
+
+// Banker is implemented by buses with banked memory, like the cartridge
+// ROM and RAM behind Memory.
+type Banker interface {
+	// Bank returns the number of the bank currently mapped at addr.
+	Bank(addr uint16) int
+	// ReadBank reads addr from the given bank, whether it is mapped or not.
+	ReadBank(bank int, addr uint16) uint8
+	// WriteBank writes addr in the given bank directly, which allows to
+	// patch ROM. It returns false if there is no such memory.
+	WriteBank(bank int, addr uint16, val uint8) bool
+}
+
+// Faulter is implemented by buses that report invalid accesses.
+// Fault returns the first error since the last call and clears it.
+type Faulter interface {
+	Fault() error
+}
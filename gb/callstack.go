@@ -0,0 +1,131 @@
+package gb
+
+import "fmt"
+
+// maxCallDepth limits the shadow call stack for programs that never return.
+const maxCallDepth = 1024
+
+// CallFrame is an entry of the shadow call stack the CPU keeps for debugging.
+type CallFrame struct {
+	// Caller is the address of the CALL or RST instruction, or the address
+	// of the interrupted instruction for interrupts.
+	Caller uint16
+	// Target is the address of the subroutine or interrupt handler.
+	Target uint16
+	// Return is the address the subroutine is expected to return to.
+	Return uint16
+	// SP is the stack pointer after the return address has been pushed.
+	SP uint16
+	// Interrupt is set for the frames of interrupt handlers.
+	Interrupt bool
+}
+
+// callStack tracks subroutine calls and returns. Programs may return
+// elsewhere or drop frames by changing SP directly; such returns are counted
+// as mismatches and the stack is repaired as well as possible.
+type callStack struct {
+	frames     []CallFrame
+	mismatches uint
+	// lastMismatch is the address of the last return that did not match a call.
+	lastMismatch uint16
+}
+
+func (s *callStack) push(f CallFrame) {
+	if len(s.frames) == maxCallDepth {
+		s.frames = append(s.frames[:0], s.frames[1:]...)
+	}
+	s.frames = append(s.frames, f)
+}
+
+// pop handles a return at addr with the stack pointer sp (before the return)
+// to the address ret.
+func (s *callStack) pop(addr, sp, ret uint16) {
+	n := len(s.frames)
+	if n > 0 && s.frames[n-1].SP == sp && s.frames[n-1].Return == ret {
+		s.frames = s.frames[:n-1]
+		return
+	}
+
+	s.mismatches += 1
+	s.lastMismatch = addr
+	// Drop the frames at or below sp, their return addresses are gone.
+	for n > 0 && s.frames[n-1].SP <= sp {
+		n -= 1
+	}
+	s.frames = s.frames[:n]
+}
+
+func (s *callStack) reset() {
+	s.frames = nil
+	s.mismatches = 0
+}
+
+// trackCall updates the shadow call stack after the instruction opCode at
+// addr was executed with the stack pointer sp.
+func (c *CPU) trackCall(opCode uint16, addr, sp uint16) {
+	if opCode > 0xFF {
+		return
+	}
+	if call, length := isCall(uint8(opCode)); call && c.reg.SP == sp-2 {
+		c.calls.push(CallFrame{
+			Caller: addr,
+			Target: c.reg.PC,
+			Return: addr + length,
+			SP:     c.reg.SP,
+		})
+	} else if isReturn(uint8(opCode)) && c.reg.SP == sp+2 {
+		c.calls.pop(addr, sp, c.reg.PC)
+	}
+}
+
+// trackInterrupt pushes a frame for the dispatch of an interrupt to the
+// handler at vector. The interrupt dispatch calls it after PC has been pushed;
+// addr is the address of the interrupted instruction.
+func (c *CPU) trackInterrupt(addr, vector uint16) {
+	c.calls.push(CallFrame{
+		Caller:    addr,
+		Target:    vector,
+		Return:    addr,
+		SP:        c.reg.SP,
+		Interrupt: true,
+	})
+}
+
+// CallStack returns the shadow call stack with the innermost call last.
+func (e *Emulator) CallStack() []CallFrame {
+	return append([]CallFrame(nil), e.cpu.calls.frames...)
+}
+
+// backtrace prints the shadow call stack, innermost frame first.
+func (d *Debugger) backtrace() {
+	if d.calls == nil {
+		fmt.Println("No call stack available")
+		return
+	}
+	frames := d.calls.frames
+	function := func(i int) string {
+		if i < 0 {
+			return "?"
+		}
+		return d.describe(frames[i].Target)
+	}
+
+	n := len(frames)
+	fmt.Printf("#0  %v in %v\n", d.describe(d.reg.PC), function(n-1))
+	for i := n - 1; i >= 0; i -= 1 {
+		f := frames[i]
+		kind := "called"
+		if f.Interrupt {
+			kind = "interrupted"
+		}
+		fmt.Printf("#%-2v %v in %v (%v from %v, SP=0x%04X)\n",
+			n-i, d.describe(f.Return), function(i-1), kind, d.describe(f.Caller), f.SP)
+	}
+	if n > 0 && d.reg.SP > frames[n-1].SP {
+		fmt.Printf("Warning: SP=0x%04X is above the innermost frame\n", d.reg.SP)
+	}
+	if d.calls.mismatches > 0 {
+		fmt.Printf("Warning: %v returns did not match a call (last at 0x%04X)\n",
+			d.calls.mismatches, d.calls.lastMismatch)
+	}
+}
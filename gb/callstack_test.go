@@ -0,0 +1,121 @@
+package gb
+
+import "testing"
+
+func TestCallStack(t *testing.T) {
+	tests := []struct {
+		name string
+		// code maps addresses to the instructions placed there.
+		code           map[uint16][]byte
+		steps          int
+		wantDepth      int
+		wantMismatches uint
+		wantLast       uint16
+	}{
+		{
+			name: "call and return",
+			code: map[uint16][]byte{
+				0x0100: {0xCD, 0x00, 0x02}, // CALL 0x0200
+				0x0200: {0xC9},             // RET
+			},
+			steps: 2,
+		},
+		{
+			name: "nested calls",
+			code: map[uint16][]byte{
+				0x0100: {0xCD, 0x00, 0x02}, // CALL 0x0200
+				0x0200: {0xCD, 0x00, 0x03}, // CALL 0x0300
+			},
+			steps:     2,
+			wantDepth: 2,
+		},
+		{
+			name: "SP changed directly",
+			code: map[uint16][]byte{
+				0x0100: {0xCD, 0x00, 0x02}, // CALL 0x0200
+				0x0200: {0x33, 0x33, 0xC9}, // INC SP; INC SP; RET
+			},
+			steps:          4,
+			wantMismatches: 1,
+			wantLast:       0x0202,
+		},
+		{
+			name: "return elsewhere",
+			code: map[uint16][]byte{
+				0x0100: {0xCD, 0x00, 0x02}, // CALL 0x0200
+				// POP HL; LD HL,0x0150; PUSH HL; RET
+				0x0200: {0xE1, 0x21, 0x50, 0x01, 0xE5, 0xC9},
+			},
+			steps:          5,
+			wantMismatches: 1,
+			wantLast:       0x0205,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := &flatBus{}
+			for addr, code := range test.code {
+				copy(bus.mem[addr:], code)
+			}
+			cpu := NewCPU(bus)
+			cpu.reg.PC = 0x0100
+			cpu.reg.SP = 0xFFFE
+			for i := 0; i < test.steps; i += 1 {
+				if _, err := cpu.Step(); err != nil {
+					t.Fatalf("Could not execute step %v: %v", i, err)
+				}
+			}
+
+			calls := &cpu.calls
+			if len(calls.frames) != test.wantDepth {
+				t.Errorf("depth: got %v, want %v", len(calls.frames), test.wantDepth)
+			}
+			if calls.mismatches != test.wantMismatches {
+				t.Errorf("mismatches: got %v, want %v", calls.mismatches, test.wantMismatches)
+			}
+			if calls.lastMismatch != test.wantLast {
+				t.Errorf("last mismatch: got 0x%04X, want 0x%04X", calls.lastMismatch, test.wantLast)
+			}
+		})
+	}
+}
+
+// RST, RETI and interrupts are not executed by the CPU yet, so the test
+// tracks their effect on PC and SP directly.
+func TestCallStackRSTAndInterrupt(t *testing.T) {
+	cpu := NewCPU(&flatBus{})
+
+	// RST 0x38 at 0x0200
+	cpu.reg.PC, cpu.reg.SP = 0x0038, 0xFFFC
+	cpu.trackCall(0xFF, 0x0200, 0xFFFE)
+	want := CallFrame{Caller: 0x0200, Target: 0x0038, Return: 0x0201, SP: 0xFFFC}
+	if frames := cpu.calls.frames; len(frames) != 1 || frames[0] != want {
+		t.Fatalf("after RST: got %v, want %v", frames, []CallFrame{want})
+	}
+
+	// VBlank interrupt before the instruction at 0x0038
+	cpu.reg.PC, cpu.reg.SP = 0x0040, 0xFFFA
+	cpu.trackInterrupt(0x0038, 0x0040)
+	want = CallFrame{Caller: 0x0038, Target: 0x0040, Return: 0x0038, SP: 0xFFFA, Interrupt: true}
+	if frames := cpu.calls.frames; len(frames) != 2 || frames[1] != want {
+		t.Fatalf("after interrupt: got %v, want frame %v", frames, want)
+	}
+
+	// RETI at 0x0040
+	cpu.reg.PC, cpu.reg.SP = 0x0038, 0xFFFC
+	cpu.trackCall(0xD9, 0x0040, 0xFFFA)
+	if frames := cpu.calls.frames; len(frames) != 1 {
+		t.Errorf("after RETI: got %v, want the frame of RST", frames)
+	}
+
+	// RET at 0x0038
+	cpu.reg.PC, cpu.reg.SP = 0x0201, 0xFFFE
+	cpu.trackCall(0xC9, 0x0038, 0xFFFC)
+	if frames := cpu.calls.frames; len(frames) != 0 {
+		t.Errorf("after RET: got %v, want no frames", frames)
+	}
+	if cpu.calls.mismatches != 0 {
+		t.Errorf("mismatches: got %v, want 0", cpu.calls.mismatches)
+	}
+}
@@ -0,0 +1,250 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+const romBankSize = 0x4000
+const ramBankSize = 0x2000
+
+const cartTypeAddr = 0x0147
+const cartRAMSizeAddr = 0x0149
+const cartHeaderEnd = 0x0150
+
+type mbcType int
+
+const (
+	mbcNone mbcType = iota
+	mbc1
+	mbc5
+)
+
+// Cartridge is a game cartridge with its ROM, optional external RAM and
+// memory bank controller (MBC).
+type Cartridge struct {
+	rom        []byte
+	ram        []byte
+	mbc        mbcType
+	ramEnabled bool
+	// romBank is the lower ROM bank register (MBC1: 5 bits, MBC5: 9 bits).
+	romBank uint16
+	// bank2 is the upper ROM bank / RAM bank register.
+	bank2 uint8
+	// mode is the MBC1 banking mode select.
+	mode uint8
+	// romChecksum is the CRC-32 of the ROM as loaded. It identifies the
+	// cartridge in save states and movies, also after the ROM was patched.
+	romChecksum uint32
+	log         Logger
+}
+
+// NewCartridge creates a cartridge from the content of a ROM file.
+// The cartridge type and RAM size are taken from the cartridge header.
+func NewCartridge(rom []byte) (*Cartridge, error) {
+	if len(rom) < cartHeaderEnd {
+		return nil, errors.New("cartridge ROM is too small to contain a header")
+	}
+
+	var mbc mbcType
+	switch rom[cartTypeAddr] {
+	case 0x00, 0x08, 0x09:
+		mbc = mbcNone
+	case 0x01, 0x02, 0x03:
+		mbc = mbc1
+	case 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E:
+		mbc = mbc5
+	default:
+		return nil, fmt.Errorf("unsupported cartridge type 0x%02X", rom[cartTypeAddr])
+	}
+
+	var ramSize int
+	switch rom[cartRAMSizeAddr] {
+	case 0x02:
+		ramSize = 0x2000
+	case 0x03:
+		ramSize = 0x8000
+	case 0x04:
+		ramSize = 0x20000
+	case 0x05:
+		ramSize = 0x10000
+	}
+
+	// Pad the ROM to a whole number of banks so bank masking never reads
+	// past the end of the slice.
+	banks := (len(rom) + romBankSize - 1) / romBankSize
+	if banks < 2 {
+		banks = 2
+	}
+	padded := make([]byte, banks*romBankSize)
+	for i := len(rom); i < len(padded); i += 1 {
+		padded[i] = 0xFF
+	}
+	copy(padded, rom)
+
+	return &Cartridge{
+		rom:         padded,
+		ram:         make([]byte, ramSize),
+		mbc:         mbc,
+		romBank:     1,
+		romChecksum: crc32.ChecksumIEEE(padded),
+		log:         nopLogger{},
+	}, nil
+}
+
+// Title returns the game title from the cartridge header.
+func (c *Cartridge) Title() string {
+	return strings.TrimRight(string(c.rom[0x0134:0x0144]), "\x00")
+}
+
+// ROMBank returns the number of the ROM bank currently mapped to 0x4000-0x7FFF.
+func (c *Cartridge) ROMBank() int {
+	switch c.mbc {
+	case mbc1:
+		return int(uint16(c.bank2)<<5|c.romBank) & c.romBankMask()
+	case mbc5:
+		return int(c.romBank) & c.romBankMask()
+	}
+	return 1
+}
+
+// romBank0 returns the number of the ROM bank currently mapped to 0x0000-0x3FFF.
+func (c *Cartridge) romBank0() int {
+	if c.mbc == mbc1 && c.mode == 1 {
+		return int(c.bank2<<5) & c.romBankMask()
+	}
+	return 0
+}
+
+// ramBank returns the number of the RAM bank currently mapped to 0xA000-0xBFFF.
+func (c *Cartridge) ramBank() int {
+	switch c.mbc {
+	case mbc1:
+		if c.mode == 1 {
+			return int(c.bank2)
+		}
+	case mbc5:
+		return int(c.bank2)
+	}
+	return 0
+}
+
+func (c *Cartridge) romBankMask() int {
+	return len(c.rom)/romBankSize - 1
+}
+
+// readROM reads from the ROM area at 0x0000-0x7FFF.
+func (c *Cartridge) readROM(addr uint16) uint8 {
+	if addr < 0x4000 {
+		return c.rom[c.romBank0()*romBankSize+int(addr)]
+	}
+	return c.rom[c.ROMBank()*romBankSize+int(addr-0x4000)]
+}
+
+// readROMBank reads addr in the ROM area from the given bank.
+// Banks that do not exist read as 0xFF.
+func (c *Cartridge) readROMBank(bank int, addr uint16) uint8 {
+	offset := bank*romBankSize + int(addr%romBankSize)
+	if bank < 0 || offset >= len(c.rom) {
+		return 0xFF
+	}
+	return c.rom[offset]
+}
+
+// writeROMBank patches the ROM at addr in the given bank.
+func (c *Cartridge) writeROMBank(bank int, addr uint16, val uint8) bool {
+	offset := bank*romBankSize + int(addr%romBankSize)
+	if bank < 0 || offset >= len(c.rom) {
+		return false
+	}
+	c.rom[offset] = val
+	return true
+}
+
+// writeROM handles writes to the ROM area, which set the MBC registers.
+func (c *Cartridge) writeROM(addr uint16, val uint8) {
+	if c.log.Enabled(LogCart, LogDebug) {
+		defer func(bank int) {
+			if c.ROMBank() != bank {
+				c.log.Logf(LogCart, LogDebug, "Switched to ROM bank %v", c.ROMBank())
+			}
+		}(c.ROMBank())
+	}
+
+	switch c.mbc {
+	case mbc1:
+		if addr < 0x2000 {
+			c.ramEnabled = (val & 0x0F) == 0x0A
+		} else if addr < 0x4000 {
+			c.romBank = uint16(val & 0x1F)
+			if c.romBank == 0 {
+				c.romBank = 1
+			}
+		} else if addr < 0x6000 {
+			c.bank2 = val & 0x03
+		} else {
+			c.mode = val & 0x01
+		}
+	case mbc5:
+		if addr < 0x2000 {
+			c.ramEnabled = (val & 0x0F) == 0x0A
+		} else if addr < 0x3000 {
+			c.romBank = (c.romBank & 0x100) | uint16(val)
+		} else if addr < 0x4000 {
+			c.romBank = (c.romBank & 0xFF) | (uint16(val&0x01) << 8)
+		} else if addr < 0x6000 {
+			c.bank2 = val & 0x0F
+		}
+	}
+}
+
+// readRAM reads from the external RAM area at 0xA000-0xBFFF.
+// Disabled or missing RAM reads as 0xFF.
+func (c *Cartridge) readRAM(addr uint16) uint8 {
+	offset, ok := c.ramOffset(addr)
+	if !ok {
+		return 0xFF
+	}
+	return c.ram[offset]
+}
+
+// writeRAM writes to the external RAM area at 0xA000-0xBFFF.
+// Writes to disabled or missing RAM are ignored.
+func (c *Cartridge) writeRAM(addr uint16, val uint8) {
+	offset, ok := c.ramOffset(addr)
+	if !ok {
+		return
+	}
+	c.ram[offset] = val
+}
+
+// readRAMBank reads addr in the external RAM area from the given bank,
+// even if the RAM is disabled. Missing RAM reads as 0xFF.
+func (c *Cartridge) readRAMBank(bank int, addr uint16) uint8 {
+	offset := bank*ramBankSize + int(addr-0xA000)
+	if bank < 0 || offset >= len(c.ram) {
+		return 0xFF
+	}
+	return c.ram[offset]
+}
+
+// writeRAMBank writes the external RAM at addr in the given bank, even if
+// the RAM is disabled.
+func (c *Cartridge) writeRAMBank(bank int, addr uint16, val uint8) bool {
+	offset := bank*ramBankSize + int(addr-0xA000)
+	if bank < 0 || offset >= len(c.ram) {
+		return false
+	}
+	c.ram[offset] = val
+	return true
+}
+
+func (c *Cartridge) ramOffset(addr uint16) (int, bool) {
+	if len(c.ram) == 0 || (c.mbc != mbcNone && !c.ramEnabled) {
+		return 0, false
+	}
+	offset := c.ramBank()*ramBankSize + int(addr-0xA000)
+	return offset % len(c.ram), true
+}
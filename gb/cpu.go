@@ -0,0 +1,110 @@
+package gb
+
+import "fmt"
+
+// illegalOpcodes are the op codes that do not exist on the SM83.
+var illegalOpcodes = map[uint16]bool{
+	0xD3: true, 0xDB: true, 0xDD: true, 0xE3: true, 0xE4: true, 0xEB: true,
+	0xEC: true, 0xED: true, 0xF4: true, 0xFC: true, 0xFD: true,
+}
+
+type CPU struct {
+	mem   Bus
+	reg   Registers
+	trace bool
+	// locked is set after an illegal op code locked up the CPU.
+	locked bool
+	// debugTrap makes LD B,B stop the emulation with an ErrDebugTrap.
+	debugTrap bool
+	// calls is the shadow call stack.
+	calls callStack
+	// symbols label the addresses in the trace.
+	symbols *Symbols
+}
+
+func NewCPU(mem Bus) *CPU {
+	return &CPU{
+		mem: mem,
+		reg: Registers{},
+	}
+}
+
+// Step fetches and executes a single instruction and returns the number
+// of clock cycles it took. If the instruction can not be executed PC is left
+// at its address.
+func (c *CPU) Step() (uint, error) {
+	if c.locked {
+		// A locked up CPU does nothing but the clock keeps running.
+		return 4, nil
+	}
+
+	instrAddr := c.reg.PC
+	opCode := uint16(c.mem.Read8(c.reg.PC))
+	c.reg.PC += 1
+
+	if opCode == opCodeExt {
+		opCode = (opCode << 8) | uint16(c.mem.Read8(c.reg.PC))
+		c.reg.PC += 1
+	}
+	if err := c.fault(); err != nil {
+		c.reg.PC = instrAddr
+		return 0, err
+	}
+
+	instr, ok := instruction[opCode]
+	if !ok {
+		c.reg.PC = instrAddr
+		if illegalOpcodes[opCode] {
+			return 0, ErrIllegalOpcode{Op: opCode, PC: instrAddr}
+		}
+		return 0, ErrUnimplementedOpcode{Op: opCode, PC: instrAddr}
+	}
+
+	cycles := instr.Cycles
+	if extra, ok := branchCycles[opCode]; ok && conditionMet(opCode, &c.reg) {
+		cycles += extra
+	}
+
+	sp := c.reg.SP
+	instr.Exec(c.mem, &c.reg)
+	c.trackCall(opCode, instrAddr, sp)
+	c.mem.Tick(cycles)
+	if c.trace {
+		fmt.Printf("Executed 0x%04X [%v] at %v. Next instruction at %v\n",
+			opCode, instr.Name, c.symbols.describe(busBank(c.mem, instrAddr), instrAddr),
+			c.symbols.describe(busBank(c.mem, c.reg.PC), c.reg.PC))
+	}
+	if err := c.fault(); err != nil {
+		return cycles, err
+	}
+	if c.debugTrap && opCode == opCodeDebugTrap {
+		return cycles, ErrDebugTrap{PC: instrAddr}
+	}
+	return cycles, nil
+}
+
+// fault returns the error of an invalid memory access during the current
+// instruction if the bus reports them.
+func (c *CPU) fault() error {
+	if f, ok := c.mem.(Faulter); ok {
+		return f.Fault()
+	}
+	return nil
+}
+
+func (c *CPU) reset() {
+	c.reg.Reset()
+	c.locked = false
+	c.calls.reset()
+}
+
+// skipBoot sets the registers to the values the DMG boot ROM leaves behind
+// when it hands over to the cartridge.
+func (c *CPU) skipBoot() {
+	c.reg.SetAF(0x01B0)
+	c.reg.SetBC(0x0013)
+	c.reg.SetDE(0x00D8)
+	c.reg.SetHL(0x014D)
+	c.reg.SP = 0xFFFE
+	c.reg.PC = 0x0100
+}
@@ -0,0 +1,637 @@
+package gb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Variable references of the scopes. The regions of the memory scope have
+// dapRegionRef plus their index in dapRegions.
+const dapRegistersRef = 1
+const dapFlagsRef = 2
+const dapMemoryRef = 3
+const dapRegionRef = 16
+
+// dapRowSize is the number of bytes shown per variable of a memory region.
+const dapRowSize = 16
+
+// dapThreadID is the id of the only thread, the CPU.
+const dapThreadID = 1
+
+var dapRegions = []struct {
+	name       string
+	start, end uint16
+}{
+	{"ROM0", 0x0000, 0x3FFF},
+	{"ROMX", 0x4000, 0x7FFF},
+	{"VRAM", 0x8000, 0x9FFF},
+	{"SRAM", 0xA000, 0xBFFF},
+	{"WRAM", 0xC000, 0xDFFF},
+	{"OAM", 0xFE00, 0xFE9F},
+	{"I/O", 0xFF00, 0xFF7F},
+	{"HRAM", 0xFF80, 0xFFFE},
+	{"IE", 0xFFFF, 0xFFFF},
+}
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type dapBreakpoint struct {
+	ID       uint       `json:"id,omitempty"`
+	Verified bool       `json:"verified"`
+	Message  string     `json:"message,omitempty"`
+	Source   *dapSource `json:"source,omitempty"`
+	Line     int        `json:"line,omitempty"`
+}
+
+type dapStackFrame struct {
+	ID                          int        `json:"id"`
+	Name                        string     `json:"name"`
+	Source                      *dapSource `json:"source,omitempty"`
+	Line                        int        `json:"line"`
+	Column                      int        `json:"column"`
+	InstructionPointerReference string     `json:"instructionPointerReference"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+	IndexedVariables   int    `json:"indexedVariables,omitempty"`
+}
+
+// dapServer lets an editor control the debugger over the Debug Adapter
+// Protocol instead of the prompt on stdin. When the editor disconnects,
+// the emulation continues without debugger.
+type dapServer struct {
+	d *Debugger
+	w io.Writer
+	// requests receives the requests read by a goroutine. It is closed at
+	// the end of the input.
+	requests chan dapRequest
+	// pending is the number of requests that wait to be handled.
+	pending int32
+	seq     int
+	sources *sourceMap
+	// configured is set once the editor sent its configuration and the
+	// emulation runs.
+	configured  bool
+	stopOnEntry bool
+	running     bool
+	// pausing is set when the editor asked the running emulation to stop.
+	pausing bool
+	// breaks are the ids of the breakpoints set per source file and
+	// functionBreaks those set by name.
+	breaks         map[string][]uint
+	functionBreaks []uint
+}
+
+// ServeDAP lets an editor control the emulation over the Debug Adapter
+// Protocol with the requests read from r and the responses and events
+// written to w. The emulation waits for the configuration of the editor
+// before the first instruction.
+func (e *Emulator) ServeDAP(r io.Reader, w io.Writer) {
+	s := &dapServer{
+		d:        e.dbg,
+		w:        w,
+		requests: make(chan dapRequest),
+		sources:  newSourceMap(),
+		breaks:   make(map[string][]uint),
+	}
+	go s.read(r)
+	e.dbg.frontend = s
+	e.dbg.Enabled = true
+	e.dbg.stepMode = true
+}
+
+// interrupted handles the requests that arrived while the emulation runs.
+func (s *dapServer) interrupted() bool {
+	if !s.running {
+		// Before the configuration is done, the requests are handled once
+		// the emulation halts before the first instruction.
+		return false
+	}
+	for atomic.LoadInt32(&s.pending) > 0 {
+		req, ok := s.next()
+		if !ok {
+			s.detach()
+			return false
+		}
+		s.handle(req)
+	}
+	return s.pausing
+}
+
+func (s *dapServer) halted() {
+	if s.running {
+		s.stopped(s.stopReason())
+	}
+	s.running = false
+	s.pausing = false
+	for {
+		req, ok := s.next()
+		if !ok {
+			s.detach()
+			return
+		}
+		if s.handle(req) {
+			s.running = true
+			return
+		}
+	}
+}
+
+func (s *dapServer) stopReason() string {
+	switch {
+	case s.pausing:
+		return "pause"
+	case len(s.d.lastHits) > 0:
+		return "data breakpoint"
+	case s.d.lastBreak != 0:
+		return "breakpoint"
+	}
+	return "step"
+}
+
+func (s *dapServer) stopped(reason string) {
+	body := map[string]interface{}{
+		"reason":            reason,
+		"threadId":          dapThreadID,
+		"allThreadsStopped": true,
+	}
+	if reason == "breakpoint" {
+		body["hitBreakpointIds"] = []uint{s.d.lastBreak}
+	}
+	s.event("stopped", body)
+}
+
+// detach removes the breakpoints of the editor and disables the debugger.
+func (s *dapServer) detach() {
+	for path, ids := range s.breaks {
+		for _, id := range ids {
+			s.d.deleteBreakpoint(id)
+		}
+		delete(s.breaks, path)
+	}
+	for _, id := range s.functionBreaks {
+		s.d.deleteBreakpoint(id)
+	}
+	s.functionBreaks = nil
+	s.running = false
+	s.d.Enabled = false
+	s.d.stepMode = false
+}
+
+// read reads the requests from r until its end.
+func (s *dapServer) read(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		data, err := readDAPMessage(br)
+		if err != nil {
+			break
+		}
+		var req dapRequest
+		if err := json.Unmarshal(data, &req); err != nil || req.Type != "request" {
+			continue
+		}
+		atomic.AddInt32(&s.pending, 1)
+		s.requests <- req
+	}
+	atomic.AddInt32(&s.pending, 1)
+	close(s.requests)
+}
+
+// next waits for the next request. It returns false at the end of the input.
+func (s *dapServer) next() (dapRequest, bool) {
+	req, ok := <-s.requests
+	atomic.AddInt32(&s.pending, -1)
+	return req, ok
+}
+
+// readDAPMessage reads the content of a message with its Content-Length header.
+func readDAPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && length >= 0 {
+			break
+		}
+		if strings.HasPrefix(line, "Content-Length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+			if err != nil {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+func (s *dapServer) write(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(s.w, "Content-Length: %v\r\n\r\n%s", len(data), data)
+}
+
+func (s *dapServer) respond(req dapRequest, body interface{}) {
+	s.seq += 1
+	s.write(dapResponse{s.seq, "response", req.Seq, true, req.Command, "", body})
+}
+
+func (s *dapServer) fail(req dapRequest, message string) {
+	s.seq += 1
+	s.write(dapResponse{s.seq, "response", req.Seq, false, req.Command, message, nil})
+}
+
+func (s *dapServer) event(name string, body interface{}) {
+	s.seq += 1
+	s.write(dapEvent{s.seq, "event", name, body})
+}
+
+// handle answers a request and returns whether the emulation continues.
+func (s *dapServer) handle(req dapRequest) bool {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+		})
+		s.event("initialized", nil)
+	case "launch", "attach":
+		// The emulator runs already, so both only configure the session.
+		var args struct {
+			StopOnEntry bool `json:"stopOnEntry"`
+			// Sources are the assembler sources to map the stack
+			// frames to. Files with breakpoints are added on their own.
+			Sources []string `json:"sources"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		s.stopOnEntry = args.StopOnEntry
+		for _, path := range args.Sources {
+			if err := s.sources.load(path); err != nil {
+				s.fail(req, err.Error())
+				return false
+			}
+		}
+		s.respond(req, nil)
+	case "configurationDone":
+		s.respond(req, nil)
+		s.configured = true
+		if s.stopOnEntry {
+			s.stopped("entry")
+			return false
+		}
+		s.d.stepMode = false
+		return true
+	case "setBreakpoints":
+		s.setBreakpoints(req)
+	case "setFunctionBreakpoints":
+		s.setFunctionBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.respond(req, nil)
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThreadID, "name": "SM83"}},
+		})
+	case "stackTrace":
+		s.stackTrace(req)
+	case "scopes":
+		s.respond(req, map[string]interface{}{
+			"scopes": []dapScope{
+				{"Registers", dapRegistersRef, false},
+				{"Flags", dapFlagsRef, false},
+				{"Memory", dapMemoryRef, true},
+			},
+		})
+	case "variables":
+		s.variables(req)
+	case "evaluate":
+		s.evaluate(req)
+	case "continue":
+		s.d.stepMode = false
+		s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+		return true
+	case "next":
+		s.d.next()
+		s.respond(req, nil)
+		return true
+	case "stepIn":
+		s.d.stepMode = true
+		s.respond(req, nil)
+		return true
+	case "stepOut":
+		s.d.finish()
+		s.respond(req, nil)
+		return true
+	case "pause":
+		s.pausing = s.running
+		s.respond(req, nil)
+	case "disconnect":
+		var args struct {
+			TerminateDebuggee bool `json:"terminateDebuggee"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		s.respond(req, nil)
+		if args.TerminateDebuggee {
+			fmt.Println("Exiting program...")
+			os.Exit(0)
+		}
+		s.detach()
+		return true
+	default:
+		s.fail(req, fmt.Sprintf("Unsupported request %v", req.Command))
+	}
+	return false
+}
+
+// setBreakpoints replaces the breakpoints of a source file. Only the lines
+// of labels map to addresses, as the symbol file has no addresses of the
+// other lines. Breakpoints in them are not verified and their message says so.
+func (s *dapServer) setBreakpoints(req dapRequest) {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, err.Error())
+		return
+	}
+	path := args.Source.Path
+	for _, id := range s.breaks[path] {
+		s.d.deleteBreakpoint(id)
+	}
+	delete(s.breaks, path)
+
+	loadErr := s.sources.load(path)
+	result := []dapBreakpoint{}
+	for _, b := range args.Breakpoints {
+		bp := dapBreakpoint{Source: &args.Source, Line: b.Line}
+		sym, ok := s.sources.symbolAt(s.d.symbols, path, b.Line)
+		switch {
+		case loadErr != nil:
+			bp.Message = loadErr.Error()
+		case !ok:
+			bp.Message = fmt.Sprintf("Line %v has no label: breakpoints in sources can only be set in lines "+
+				"of labels from the symbol file, use a function breakpoint for other addresses", b.Line)
+		default:
+			bp.ID = s.d.addBreakpoint(location{bank: sym.Bank, addr: sym.Addr}, b.Condition)
+			if bp.ID == 0 {
+				bp.Message = "Invalid condition"
+				break
+			}
+			bp.Verified = true
+			s.breaks[path] = append(s.breaks[path], bp.ID)
+		}
+		result = append(result, bp)
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+}
+
+// setFunctionBreakpoints replaces the breakpoints set by symbol name or address.
+func (s *dapServer) setFunctionBreakpoints(req dapRequest) {
+	var args struct {
+		Breakpoints []struct {
+			Name      string `json:"name"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, err.Error())
+		return
+	}
+	for _, id := range s.functionBreaks {
+		s.d.deleteBreakpoint(id)
+	}
+	s.functionBreaks = nil
+
+	result := []dapBreakpoint{}
+	for _, b := range args.Breakpoints {
+		var bp dapBreakpoint
+		loc, err := s.d.parseLocation(b.Name)
+		if err != nil {
+			bp.Message = err.Error()
+			result = append(result, bp)
+			continue
+		}
+		bp.ID = s.d.addBreakpoint(loc, b.Condition)
+		if bp.ID == 0 {
+			bp.Message = "Invalid condition"
+			result = append(result, bp)
+			continue
+		}
+		bp.Verified = true
+		if path, line, ok := s.sources.line(b.Name); ok {
+			bp.Source = &dapSource{Name: filepath.Base(path), Path: path}
+			bp.Line = line
+		}
+		s.functionBreaks = append(s.functionBreaks, bp.ID)
+		result = append(result, bp)
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+}
+
+// stackTrace returns the frames of the shadow call stack. They are at the
+// return addresses of the calls. Only frames at the address of a label have
+// a source line.
+func (s *dapServer) stackTrace(req dapRequest) {
+	var args struct {
+		StartFrame int `json:"startFrame"`
+		Levels     int `json:"levels"`
+	}
+	json.Unmarshal(req.Arguments, &args)
+
+	addrs := []uint16{s.d.reg.PC}
+	if s.d.calls != nil {
+		frames := s.d.calls.frames
+		for i := len(frames) - 1; i >= 0; i -= 1 {
+			addrs = append(addrs, frames[i].Return)
+		}
+	}
+	start := args.StartFrame
+	if start > len(addrs) {
+		start = len(addrs)
+	}
+	end := len(addrs)
+	if args.Levels > 0 && start+args.Levels < end {
+		end = start + args.Levels
+	}
+
+	result := []dapStackFrame{}
+	for i := start; i < end; i += 1 {
+		addr := addrs[i]
+		frame := dapStackFrame{
+			ID:                          i,
+			Name:                        fmt.Sprintf("0x%04X", addr),
+			InstructionPointerReference: fmt.Sprintf("0x%04X", addr),
+		}
+		if sym, offset, ok := s.d.symbols.Nearest(busBank(s.d.mem, addr), addr); ok {
+			frame.Name = sym.Name
+			if offset > 0 {
+				frame.Name = fmt.Sprintf("%v+%v", sym.Name, offset)
+			} else if path, line, ok := s.sources.line(sym.Name); ok {
+				frame.Source = &dapSource{Name: filepath.Base(path), Path: path}
+				frame.Line = line
+				frame.Column = 1
+			}
+		}
+		result = append(result, frame)
+	}
+	s.respond(req, map[string]interface{}{"stackFrames": result, "totalFrames": len(addrs)})
+}
+
+func (s *dapServer) variables(req dapRequest) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+		Start              int `json:"start"`
+		Count              int `json:"count"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, err.Error())
+		return
+	}
+
+	reg := s.d.reg
+	var result []dapVariable
+	switch ref := args.VariablesReference; {
+	case ref == dapRegistersRef:
+		for _, r := range []struct {
+			name string
+			val  uint8
+		}{{"A", reg.A}, {"F", reg.F}, {"B", reg.B}, {"C", reg.C}, {"D", reg.D}, {"E", reg.E}, {"H", reg.H}, {"L", reg.L}} {
+			result = append(result, dapVariable{Name: r.name, Value: fmt.Sprintf("0x%02X", r.val)})
+		}
+		for _, r := range []struct {
+			name string
+			val  uint16
+		}{{"AF", reg.AF()}, {"BC", reg.BC()}, {"DE", reg.DE()}, {"HL", reg.HL()}, {"SP", reg.SP}, {"PC", reg.PC}} {
+			result = append(result, dapVariable{Name: r.name, Value: fmt.Sprintf("0x%04X", r.val)})
+		}
+		result = append(result, dapVariable{Name: "ROM bank", Value: fmt.Sprintf("0x%02X", busBank(s.d.mem, 0x4000))})
+	case ref == dapFlagsRef:
+		for _, name := range []string{"Z", "N", "H", "C"} {
+			value := "0"
+			if reg.IsFlagSet(exprFlags[name+"F"]) {
+				value = "1"
+			}
+			result = append(result, dapVariable{Name: name, Value: value})
+		}
+	case ref == dapMemoryRef:
+		for i, region := range dapRegions {
+			result = append(result, dapVariable{
+				Name:               region.name,
+				Value:              fmt.Sprintf("$%04X-$%04X", region.start, region.end),
+				VariablesReference: dapRegionRef + i,
+				IndexedVariables:   (int(region.end)-int(region.start))/dapRowSize + 1,
+			})
+		}
+	case ref >= dapRegionRef && ref < dapRegionRef+len(dapRegions):
+		result = s.memoryRows(ref-dapRegionRef, args.Start, args.Count)
+	default:
+		s.fail(req, fmt.Sprintf("Unknown variables reference %v", ref))
+		return
+	}
+	s.respond(req, map[string]interface{}{"variables": result})
+}
+
+// memoryRows returns count rows of a memory region from row start on.
+func (s *dapServer) memoryRows(region, start, count int) []dapVariable {
+	r := dapRegions[region]
+	rows := (int(r.end)-int(r.start))/dapRowSize + 1
+	if start < 0 || start > rows {
+		start = rows
+	}
+	if count <= 0 || start+count > rows {
+		count = rows - start
+	}
+	result := []dapVariable{}
+	for row := start; row < start+count; row += 1 {
+		addr := int(r.start) + row*dapRowSize
+		var b strings.Builder
+		for i := addr; i < addr+dapRowSize && i <= int(r.end); i += 1 {
+			if i > addr {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "%02X", s.d.mem.Read8(uint16(i)))
+		}
+		result = append(result, dapVariable{Name: fmt.Sprintf("$%04X", addr), Value: b.String()})
+	}
+	// Unmapped addresses read as 0xFF, which is fine to show.
+	s.d.fault()
+	return result
+}
+
+func (s *dapServer) evaluate(req dapRequest) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, err.Error())
+		return
+	}
+	e, err := s.d.parseExpr(args.Expression)
+	if err != nil {
+		s.fail(req, fmt.Sprintf("Invalid expression: %v", err))
+		return
+	}
+	val, err := e.eval(s.d.exprEnv())
+	if err != nil {
+		s.fail(req, err.Error())
+		return
+	}
+	s.respond(req, map[string]interface{}{
+		"result":             fmt.Sprintf("%v (0x%X)", val, val),
+		"variablesReference": 0,
+	})
+}
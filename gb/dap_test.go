@@ -0,0 +1,239 @@
+package gb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dapTestSource is the source of callTestROM after its header.
+const dapTestSource = `SECTION "Main", ROM0[$150]
+Main:
+	ld a, 1
+	call Sub
+	ld b, b
+	ret
+
+SECTION "Sub", ROM0[$160]
+Sub:
+	ld b, b
+.end
+	ret
+`
+
+// dapClient speaks the client side of the Debug Adapter Protocol.
+type dapClient struct {
+	t        *testing.T
+	w        io.Writer
+	seq      int
+	messages chan map[string]interface{}
+	// events are the events received while waiting for a response.
+	events []map[string]interface{}
+}
+
+func newDAPClient(t *testing.T, r io.Reader, w io.Writer) *dapClient {
+	c := &dapClient{t: t, w: w, messages: make(chan map[string]interface{}, 16)}
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			data, err := readDAPMessage(br)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]interface{}
+			json.Unmarshal(data, &msg)
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *dapClient) receive() map[string]interface{} {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("Connection closed")
+		}
+		return msg
+	case <-time.After(10 * time.Second):
+		c.t.Fatalf("No message received")
+	}
+	return nil
+}
+
+// request sends a request and returns the body of the successful response.
+func (c *dapClient) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.seq += 1
+	data, _ := json.Marshal(map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": args,
+	})
+	fmt.Fprintf(c.w, "Content-Length: %v\r\n\r\n%s", len(data), data)
+	for {
+		msg := c.receive()
+		if msg["type"] == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg["request_seq"] != float64(c.seq) || msg["success"] != true {
+			c.t.Fatalf("%v: unexpected response %v", command, msg)
+		}
+		body, _ := msg["body"].(map[string]interface{})
+		return body
+	}
+}
+
+// event waits for an event and returns its body.
+func (c *dapClient) event(name string) map[string]interface{} {
+	c.t.Helper()
+	for {
+		var msg map[string]interface{}
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.receive()
+		}
+		if msg["type"] == "event" && msg["event"] == name {
+			body, _ := msg["body"].(map[string]interface{})
+			return body
+		}
+	}
+}
+
+// expectStop waits for a stopped event and checks its reason and PC.
+func (c *dapClient) expectStop(reason string, pc uint16) {
+	c.t.Helper()
+	if body := c.event("stopped"); body["reason"] != reason {
+		c.t.Fatalf("Stopped for %v, want %v", body["reason"], reason)
+	}
+	want := fmt.Sprintf("%v (0x%X)", pc, pc)
+	if got := c.request("evaluate", map[string]interface{}{"expression": "PC"})["result"]; got != want {
+		c.t.Fatalf("PC is %v, want %v", got, want)
+	}
+}
+
+func TestDAPServer(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "main.asm")
+	if err := os.WriteFile(source, []byte(dapTestSource), 0644); err != nil {
+		t.Fatalf("Could not write source: %v", err)
+	}
+	symbols, err := ParseSymbols(strings.NewReader("00:0150 Main\n00:0160 Sub\n00:0161 Sub.end\n"))
+	if err != nil {
+		t.Fatalf("Could not parse symbols: %v", err)
+	}
+	emu, err := NewEmulator(nil, callTestROM())
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	emu.SetSymbols(symbols)
+	requests, requestWriter := io.Pipe()
+	responseReader, responses := io.Pipe()
+	defer requestWriter.Close()
+	emu.ServeDAP(requests, responses)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := emu.StepInstruction(); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := newDAPClient(t, responseReader, requestWriter)
+	if body := c.request("initialize", map[string]interface{}{"adapterID": "gb"}); body["supportsConfigurationDoneRequest"] != true {
+		t.Fatalf("Unexpected capabilities %v", body)
+	}
+	c.event("initialized")
+	c.request("launch", map[string]interface{}{})
+
+	// Sub is defined in line 9, line 10 and line 1 have no label.
+	body := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": source},
+		"breakpoints": []map[string]interface{}{{"line": 9}, {"line": 10}, {"line": 1}},
+	})
+	bps := body["breakpoints"].([]interface{})
+	if bp := bps[0].(map[string]interface{}); bp["verified"] != true || bp["line"] != float64(9) {
+		t.Fatalf("Unexpected breakpoint %v", bp)
+	}
+	for _, bp := range bps[1:] {
+		bp := bp.(map[string]interface{})
+		if bp["verified"] != false {
+			t.Fatalf("Breakpoint without label verified: %v", bp)
+		}
+		if msg, _ := bp["message"].(string); !strings.Contains(msg, "has no label") {
+			t.Errorf("Unexpected message of a breakpoint without label: %q", msg)
+		}
+	}
+	c.request("configurationDone", nil)
+	c.expectStop("breakpoint", 0x160)
+
+	frames := c.request("stackTrace", map[string]interface{}{"threadId": 1})["stackFrames"].([]interface{})
+	var names []string
+	for _, f := range frames {
+		names = append(names, f.(map[string]interface{})["name"].(string))
+	}
+	if got := strings.Join(names, " "); got != "Sub Main+5 0x0103" {
+		t.Fatalf("Got frames %v, want Sub Main+5 0x0103", got)
+	}
+	if f := frames[0].(map[string]interface{}); f["line"] != float64(9) {
+		t.Fatalf("Frame of Sub is in line %v, want 9", f["line"])
+	}
+	if f := frames[1].(map[string]interface{}); f["source"] != nil {
+		t.Fatalf("Frame of Main+5 has source %v", f["source"])
+	}
+
+	c.request("scopes", map[string]interface{}{"frameId": 0})
+	vars := c.request("variables", map[string]interface{}{"variablesReference": dapRegistersRef})["variables"].([]interface{})
+	found := false
+	for _, v := range vars {
+		v := v.(map[string]interface{})
+		if v["name"] == "PC" {
+			found = v["value"] == "0x0160"
+		}
+	}
+	if !found {
+		t.Fatalf("PC missing in registers %v", vars)
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "Sub.end - Main"})
+	rows := c.request("variables", map[string]interface{}{
+		"variablesReference": dapRegionRef + 4, "start": 0, "count": 1,
+	})["variables"].([]interface{})
+	if row := rows[0].(map[string]interface{}); len(rows) != 1 || row["name"] != "$C000" {
+		t.Fatalf("Unexpected WRAM rows %v", rows)
+	}
+
+	c.request("next", map[string]interface{}{"threadId": 1})
+	c.expectStop("step", 0x161)
+	c.request("stepOut", map[string]interface{}{"threadId": 1})
+	c.expectStop("step", 0x155)
+	c.request("stepIn", map[string]interface{}{"threadId": 1})
+	c.expectStop("step", 0x156)
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	c.expectStop("breakpoint", 0x160)
+
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": source},
+		"breakpoints": []map[string]interface{}{},
+	})
+	c.request("continue", map[string]interface{}{"threadId": 1})
+	c.request("pause", map[string]interface{}{"threadId": 1})
+	if body := c.event("stopped"); body["reason"] != "pause" {
+		t.Fatalf("Stopped for %v, want pause", body["reason"])
+	}
+	c.request("disconnect", nil)
+}
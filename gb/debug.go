@@ -0,0 +1,666 @@
+package gb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Debugger struct {
+	Enabled bool
+	mem     Bus
+	reg     *Registers
+	breaks  map[uint]Breakpoint
+	// watch is the bus of the CPU. It checks the watchpoints.
+	watch      *watchBus
+	breakCount uint
+	stepMode   bool
+	// stepsLeft is the number of instructions to execute before stepMode stops again.
+	stepsLeft uint
+	// stop is the condition of next, finish and until. It is checked before
+	// each instruction while set.
+	stop func() bool
+	// symbols are the labels accepted in place of addresses.
+	symbols *Symbols
+	// calls is the shadow call stack of the CPU.
+	calls *callStack
+	// lastHits are the watchpoint hits the emulation stopped at.
+	lastHits []watchHit
+	// frontend controls the debugger instead of the prompt on stdin if set.
+	frontend frontend
+	// lastBreak is the id of the breakpoint the emulation stopped at or 0.
+	lastBreak uint
+	frame     *Framebuffer
+	recorder  *Recorder
+	input     *bufio.Scanner
+	// reverse steps back one instruction or to the last breakpoint hit.
+	// It is nil if rewinding is not enabled.
+	reverse func(toBreakpoint bool) error
+	// Palette and Scale are used for screenshots.
+	Palette Palette
+	Scale   int
+}
+
+// frontend is a remote user interface of the debugger.
+type frontend interface {
+	// halted is called when the emulation stopped. It returns when the
+	// emulation continues.
+	halted()
+	// interrupted reports whether the user wants the running emulation to stop.
+	interrupted() bool
+}
+
+func NewDebugger(mem Bus, reg *Registers, frame *Framebuffer) *Debugger {
+	return &Debugger{
+		mem:        mem,
+		reg:        reg,
+		frame:      frame,
+		Palette:    PaletteDMG,
+		Scale:      1,
+		breaks:     make(map[uint]Breakpoint),
+		watch:      &watchBus{bus: mem},
+		breakCount: 0,
+		stepMode:   true,
+	}
+}
+
+type Breakpoint struct {
+	id uint
+	// loc is the address and, if given, the bank of the breakpoint.
+	loc     location
+	enabled bool
+	// condition must evaluate to non-zero for the breakpoint to trigger.
+	// It is nil for unconditional breakpoints.
+	condition     expr
+	conditionText string
+	// hits counts how often the breakpoint was reached with its condition met.
+	hits uint
+	// ignore is the number of hits that do not stop the emulation anymore.
+	ignore uint
+}
+
+func (d *Debugger) Cycle() {
+	d.watch.begin(d.reg.PC)
+	hits := d.watch.takeHits()
+	if !d.Enabled {
+		return
+	}
+	d.lastHits = hits
+	if len(hits) > 0 {
+		for _, hit := range hits {
+			fmt.Println(hit)
+		}
+		d.halt(0)
+	} else if shouldBreak, bp := d.shouldBreakAt(d.reg.PC); shouldBreak {
+		fmt.Printf("Breakpoint %v at %v\n", bp.id, d.describe(d.reg.PC))
+		d.halt(bp.id)
+	} else if d.frontend != nil && d.frontend.interrupted() {
+		d.halt(0)
+	} else if d.stop != nil {
+		if d.stop() {
+			fmt.Printf("Stopped at %v\n", d.describe(d.reg.PC))
+			d.halt(0)
+		}
+	} else if d.stepMode {
+		if d.stepsLeft > 0 {
+			d.stepsLeft -= 1
+			return
+		}
+		d.halt(0)
+	}
+}
+
+// halt stops the emulation and processes commands until it continues.
+// breakID is the breakpoint that stopped the emulation or 0.
+func (d *Debugger) halt(breakID uint) {
+	d.stop = nil
+	d.stepsLeft = 0
+	d.lastBreak = breakID
+	if d.frontend != nil {
+		d.frontend.halted()
+		return
+	}
+	d.processInput()
+}
+
+// Break enables the debugger and stops before the next instruction.
+func (d *Debugger) Break(reason string) {
+	fmt.Printf("Break: %v\n", reason)
+	d.Enabled = true
+	d.stepMode = true
+	d.stop = nil
+}
+
+var emptyPattern = regexp.MustCompile(`^\s*$`)
+var continuePattern = regexp.MustCompile(`^(c|continue)(?: (\d+))?$`)
+var registersPattern = regexp.MustCompile(`^(i r|info registers)$`)
+var memPattern = regexp.MustCompile(`^(i m|info mem) (\S+) ([0-9a-fA-F]{1,4})$`)
+var killPattern = regexp.MustCompile(`^(k|kill)$`)
+var listBreaksPattern = regexp.MustCompile(`^(i b|info breakpoints)$`)
+var addBreakPattern = regexp.MustCompile(`^(b|break) (\S+)(?: if (.+))?$`)
+var conditionPattern = regexp.MustCompile(`^condition (\d+)(?: (.+))?$`)
+var ignorePattern = regexp.MustCompile(`^ignore (\d+) (\d+)$`)
+var printPattern = regexp.MustCompile(`^(p|print) (.+)$`)
+var watchPattern = regexp.MustCompile(`^(watch|rwatch|awatch)( -change)? (\S+)(?: (\S+))?$`)
+var listWatchesPattern = regexp.MustCompile(`^(i w|info watchpoints)$`)
+var deleteBreakPattern = regexp.MustCompile(`^(d|delete) (\d+)$`)
+var enableBreakPattern = regexp.MustCompile(`^(en|enable) (\d+)$`)
+var disableBreakPattern = regexp.MustCompile(`^(dis|disable) (\d+)$`)
+var disassemblePattern = regexp.MustCompile(`^(disas|disassemble)(?: (\S+)(?: (\d+))?)?$`)
+var stepPattern = regexp.MustCompile(`^(s|step|si|stepi)(?: (\d+))?$`)
+var setRegPattern = regexp.MustCompile(`^set reg (\w+) (.+)$`)
+var setMemPattern = regexp.MustCompile(`^set mem( -rom)? (\S+) (.+)$`)
+var fillPattern = regexp.MustCompile(`^fill( -rom)? (\S+) ([0-9a-fA-F]+) ([0-9a-fA-F]{1,2})$`)
+var loadPattern = regexp.MustCompile(`^load( -rom)? (\S+) (.+)$`)
+var backtracePattern = regexp.MustCompile(`^(bt|backtrace)$`)
+var nextPattern = regexp.MustCompile(`^(n|next)$`)
+var finishPattern = regexp.MustCompile(`^(fin|finish)$`)
+var untilPattern = regexp.MustCompile(`^(u|until) (\S+)$`)
+var screenshotPattern = regexp.MustCompile(`^screenshot (\S+)$`)
+var recordPattern = regexp.MustCompile(`^record( on| off)?$`)
+var reverseStepPattern = regexp.MustCompile(`^(rs|reverse-step)$`)
+var reverseContinuePattern = regexp.MustCompile(`^(rc|reverse-continue)$`)
+
+func (d *Debugger) processInput() {
+	for {
+		fmt.Printf("> ")
+		cmd := d.readInput()
+		cmd = strings.TrimSpace(cmd)
+		if matches := continuePattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			n, _ := strconv.ParseUint(matches[2], 10, 0)
+			d.continueN(uint(n))
+			break
+		} else if matches := stepPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			d.stepMode = true
+			if n, _ := strconv.ParseUint(matches[2], 10, 0); n > 1 {
+				d.stepsLeft = uint(n - 1)
+			}
+			break
+		} else if nextPattern.MatchString(cmd) {
+			d.next()
+			break
+		} else if finishPattern.MatchString(cmd) {
+			d.finish()
+			break
+		} else if matches := untilPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			loc, err := d.parseLocation(matches[2])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			d.until(loc)
+			break
+		} else if matches := setRegPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			d.setRegister(matches[1], matches[2])
+		} else if matches := setMemPattern.FindStringSubmatch(cmd); len(matches) == 4 {
+			d.setMemory(matches[1] != "", matches[2], matches[3])
+		} else if matches := fillPattern.FindStringSubmatch(cmd); len(matches) == 5 {
+			d.fill(matches[1] != "", matches[2], matches[3], matches[4])
+		} else if matches := loadPattern.FindStringSubmatch(cmd); len(matches) == 4 {
+			d.loadFile(matches[1] != "", matches[2], matches[3])
+		} else if backtracePattern.MatchString(cmd) {
+			d.backtrace()
+		} else if registersPattern.MatchString(cmd) {
+			d.printRegisters()
+		} else if matches := memPattern.FindStringSubmatch(cmd); len(matches) == 4 {
+			start, err := d.parseLocation(matches[2])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			size, _ := strconv.ParseUint(matches[3], 16, 16)
+			cols := uint16(16)
+			d.printMemory(start, uint16(size), cols)
+		} else if listBreaksPattern.MatchString(cmd) {
+			d.listBreakpoints()
+		} else if matches := addBreakPattern.FindStringSubmatch(cmd); len(matches) == 4 {
+			loc, err := d.parseLocation(matches[2])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			d.addBreakpoint(loc, matches[3])
+		} else if matches := conditionPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			id, _ := strconv.ParseUint(matches[1], 10, 0)
+			d.setCondition(uint(id), matches[2])
+		} else if matches := ignorePattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			id, _ := strconv.ParseUint(matches[1], 10, 0)
+			count, _ := strconv.ParseUint(matches[2], 10, 0)
+			d.setIgnoreCount(uint(id), uint(count))
+		} else if matches := printPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			d.printExpr(matches[2])
+		} else if matches := watchPattern.FindStringSubmatch(cmd); len(matches) == 5 {
+			d.parseWatchpoint(matches)
+		} else if listWatchesPattern.MatchString(cmd) {
+			d.listWatchpoints()
+		} else if matches := deleteBreakPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			id, _ := strconv.ParseUint(matches[2], 10, 0)
+			d.deleteBreakpoint(uint(id))
+		} else if matches := enableBreakPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			id, _ := strconv.ParseUint(matches[2], 10, 0)
+			d.enableBreakpoint(uint(id), true)
+		} else if matches := disableBreakPattern.FindStringSubmatch(cmd); len(matches) == 3 {
+			id, _ := strconv.ParseUint(matches[2], 10, 0)
+			d.enableBreakpoint(uint(id), false)
+		} else if killPattern.MatchString(cmd) {
+			fmt.Println("Exiting program...")
+			os.Exit(0)
+		} else if matches := disassemblePattern.FindStringSubmatch(cmd); len(matches) == 4 {
+			loc := location{bank: -1, addr: d.reg.PC}
+			if matches[2] != "" {
+				var err error
+				if loc, err = d.parseLocation(matches[2]); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					continue
+				}
+			}
+			count := uint64(disassembleCount)
+			if matches[3] != "" {
+				count, _ = strconv.ParseUint(matches[3], 10, 16)
+			}
+			d.disassemble(loc, int(count))
+		} else if matches := screenshotPattern.FindStringSubmatch(cmd); len(matches) == 2 {
+			d.screenshot(matches[1])
+		} else if matches := recordPattern.FindStringSubmatch(cmd); len(matches) == 2 {
+			d.toggleRecording(strings.TrimSpace(matches[1]))
+		} else if reverseStepPattern.MatchString(cmd) {
+			d.reverseStep(false)
+		} else if reverseContinuePattern.MatchString(cmd) {
+			d.reverseStep(true)
+		} else if !emptyPattern.MatchString(cmd) {
+			fmt.Printf("Unknown or invalid command: %v\n", cmd)
+		}
+	}
+}
+
+// shouldBreakAt returns the breakpoint that stops the emulation at pc, if
+// any, and counts the hits of all breakpoints at pc.
+func (d *Debugger) shouldBreakAt(pc uint16) (bool, *Breakpoint) {
+	var hit *Breakpoint
+	for _, id := range d.breakpointIDs() {
+		bp := d.breaks[id]
+		if !d.conditionMet(&bp, pc) {
+			continue
+		}
+		bp.hits += 1
+		if bp.ignore > 0 {
+			bp.ignore -= 1
+		} else if hit == nil {
+			hit = &bp
+		}
+		d.breaks[id] = bp
+	}
+	return hit != nil, hit
+}
+
+// breakpointAt reports whether an enabled breakpoint at pc has its condition
+// met without counting a hit.
+func (d *Debugger) breakpointAt(pc uint16) bool {
+	for _, id := range d.breakpointIDs() {
+		bp := d.breaks[id]
+		if d.conditionMet(&bp, pc) {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionMet reports whether bp is enabled, at pc in the current bank and
+// its condition holds. A condition that can not be evaluated counts as met,
+// so the emulation stops where the error can be examined.
+func (d *Debugger) conditionMet(bp *Breakpoint, pc uint16) bool {
+	if !bp.enabled || !bp.loc.matches(busBank(d.mem, pc), pc) {
+		return false
+	}
+	if bp.condition == nil {
+		return true
+	}
+	val, err := bp.condition.eval(d.exprEnv())
+	if err != nil {
+		fmt.Printf("Error in condition of breakpoint %v: %v\n", bp.id, err)
+		return true
+	}
+	return val != 0
+}
+
+// parseExpr parses an expression that may use the symbols.
+func (d *Debugger) parseExpr(s string) (expr, error) {
+	return parseExpr(s, d.symbols)
+}
+
+func (d *Debugger) exprEnv() *exprEnv {
+	return &exprEnv{reg: d.reg, mem: d.mem}
+}
+
+// breakpointIDs returns the ids of all breakpoints in ascending order.
+func (d *Debugger) breakpointIDs() []uint {
+	ids := make([]uint, 0, len(d.breaks))
+	for id := range d.breaks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// disassembleCount is the default number of instructions disassembled.
+const disassembleCount = 10
+
+// disassemble prints n instructions starting at loc. The current instruction
+// is marked with => and breakpoints with *.
+func (d *Debugger) disassemble(loc location, n int) {
+	read := func(addr uint16) uint8 {
+		return d.read(location{loc.bank, addr}, 0)
+	}
+	label := func(addr uint16) string {
+		bank := busBank(d.mem, addr)
+		if loc.bank >= 0 && memoryRegion(addr) == memoryRegion(loc.addr) {
+			bank = loc.bank
+		}
+		name, _ := d.symbols.At(bank, addr)
+		return name
+	}
+	for _, in := range DisassembleN(read, loc.addr, n) {
+		if err := d.fault(); err != nil {
+			fmt.Printf("Could not disassemble instruction at address %v (%v)\n",
+				location{loc.bank, in.Addr}, err)
+			return
+		}
+		bank := loc.bank
+		if bank < 0 {
+			bank = busBank(d.mem, in.Addr)
+		}
+
+		marker := "  "
+		if in.Addr == d.reg.PC && bank == busBank(d.mem, d.reg.PC) {
+			marker = "=>"
+		} else if d.hasBreakpoint(bank, in.Addr) {
+			marker = " *"
+		}
+		if name, ok := d.symbols.At(bank, in.Addr); ok {
+			fmt.Printf("%v:\n", name)
+		}
+		var hex strings.Builder
+		for _, b := range in.Bytes {
+			fmt.Fprintf(&hex, "%02X ", b)
+		}
+		fmt.Printf("%v %v  %-9v %v\n", marker, location{loc.bank, in.Addr}, hex.String(), in.Format(label))
+	}
+}
+
+// hasBreakpoint reports whether an enabled breakpoint is at addr in bank.
+func (d *Debugger) hasBreakpoint(bank int, addr uint16) bool {
+	for _, bp := range d.breaks {
+		if bp.enabled && bp.loc.matches(bank, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// fault returns the error of an invalid memory access by the debugger
+// if the bus reports them.
+func (d *Debugger) fault() error {
+	if f, ok := d.mem.(Faulter); ok {
+		return f.Fault()
+	}
+	return nil
+}
+
+func (d *Debugger) listBreakpoints() {
+	if len(d.breaks) > 0 {
+		fmt.Println("Num\tEnb\tAddress\tHits\tCondition")
+
+		for _, id := range d.breakpointIDs() {
+			enbStr := "y"
+			bp := d.breaks[id]
+			if !bp.enabled {
+				enbStr = "n"
+			}
+			fmt.Printf("%v\t%v\t%v\t%v\t%v", id, enbStr, bp.loc, bp.hits, bp.conditionText)
+			if bp.ignore > 0 {
+				fmt.Printf(" (ignore next %v hits)", bp.ignore)
+			}
+			fmt.Println()
+		}
+	} else {
+		fmt.Println("No Breakpoints")
+	}
+}
+
+// addBreakpoint adds a breakpoint and returns its id or 0 if the
+// condition is invalid.
+func (d *Debugger) addBreakpoint(loc location, condition string) uint {
+	bp := Breakpoint{
+		loc:     loc,
+		enabled: true,
+	}
+	if condition != "" {
+		cond, err := d.parseExpr(condition)
+		if err != nil {
+			fmt.Printf("Invalid condition: %v\n", err)
+			return 0
+		}
+		bp.condition = cond
+		bp.conditionText = condition
+	}
+	d.breakCount += 1
+	bp.id = d.breakCount
+	d.breaks[bp.id] = bp
+	return bp.id
+}
+
+// setCondition sets the condition of a breakpoint. An empty condition
+// makes it unconditional.
+func (d *Debugger) setCondition(id uint, condition string) {
+	bp, ok := d.breaks[id]
+	if !ok {
+		fmt.Printf("Unknown breakpoint: %v\n", id)
+		return
+	}
+	bp.condition = nil
+	bp.conditionText = ""
+	if condition != "" {
+		cond, err := d.parseExpr(condition)
+		if err != nil {
+			fmt.Printf("Invalid condition: %v\n", err)
+			return
+		}
+		bp.condition = cond
+		bp.conditionText = condition
+	}
+	d.breaks[id] = bp
+}
+
+func (d *Debugger) setIgnoreCount(id uint, count uint) {
+	bp, ok := d.breaks[id]
+	if !ok {
+		fmt.Printf("Unknown breakpoint: %v\n", id)
+		return
+	}
+	bp.ignore = count
+	d.breaks[id] = bp
+}
+
+func (d *Debugger) printExpr(s string) {
+	e, err := d.parseExpr(s)
+	if err != nil {
+		fmt.Printf("Invalid expression: %v\n", err)
+		return
+	}
+	val, err := e.eval(d.exprEnv())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("%v (0x%X)\n", val, val)
+}
+
+func (d *Debugger) deleteBreakpoint(id uint) {
+	if d.deleteWatchpoint(id) {
+		return
+	}
+	delete(d.breaks, id)
+}
+
+func (d *Debugger) enableBreakpoint(id uint, enabled bool) {
+	if wp := d.watchpoint(id); wp != nil {
+		wp.enabled = enabled
+		return
+	}
+	bp, ok := d.breaks[id]
+	if !ok {
+		fmt.Printf("Unknown breakpoint: %v\n", id)
+		return
+	}
+	bp.enabled = enabled
+	d.breaks[id] = bp
+}
+
+// parseWatchpoint adds a watchpoint from the matches of watchPattern.
+func (d *Debugger) parseWatchpoint(matches []string) {
+	kinds := map[string]watchKind{"watch": watchWrite, "rwatch": watchRead, "awatch": watchAccess}
+	kind := kinds[matches[1]]
+	change := matches[2] != ""
+	if change && kind != watchWrite {
+		fmt.Println("Only watch supports -change")
+		return
+	}
+	start, err := d.parseLocation(matches[3])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	end := start
+	if matches[4] != "" {
+		if end, err = d.parseLocation(matches[4]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if end.bank >= 0 && end.bank != start.bank {
+			fmt.Println("Invalid range: start and end are in different banks")
+			return
+		}
+	}
+	d.addWatchpoint(kind, start, end.addr, change)
+}
+
+func (d *Debugger) printMemory(start location, size, cols uint16) {
+	if size == 0 {
+		return
+	}
+	fmt.Printf("ADDR ")
+	for col := uint16(0); col < cols; col += 1 {
+		fmt.Printf("%02X ", col)
+	}
+	fmt.Printf("    \n")
+
+	for i := uint16(0); i < size; i += 1 {
+		if (i % cols) == 0 {
+			fmt.Printf("%04X ", start.addr+i)
+		}
+		fmt.Printf("%02X ", d.read(start, i))
+		if err := d.fault(); err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+		if ((i + 1) % cols) == 0 {
+			fmt.Printf("%04X\n", (i + 1 - cols))
+		}
+	}
+	fmt.Printf("\n")
+}
+
+func (d *Debugger) screenshot(path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	if err := d.frame.WritePNG(file, d.Palette, d.Scale); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Saved screenshot to %v\n", path)
+}
+
+func (d *Debugger) toggleRecording(state string) {
+	if d.recorder == nil {
+		fmt.Println("No recording running")
+		return
+	}
+	switch state {
+	case "on":
+		d.recorder.Active = true
+	case "off":
+		d.recorder.Active = false
+	default:
+		d.recorder.Active = !d.recorder.Active
+	}
+	if d.recorder.Active {
+		fmt.Printf("Recording on (%v frames captured)\n", d.recorder.FrameCount())
+	} else {
+		fmt.Printf("Recording off (%v frames captured)\n", d.recorder.FrameCount())
+	}
+}
+
+func (d *Debugger) reverseStep(toBreakpoint bool) {
+	if d.reverse == nil {
+		fmt.Println("Rewinding is not enabled")
+		return
+	}
+	if err := d.reverse(toBreakpoint); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Now at 0x%04X\n", d.reg.PC)
+}
+
+func (d *Debugger) printRegisters() {
+	fmt.Printf("A: 0x%02X | F: 0x%02X\n", d.reg.A, d.reg.F)
+	fmt.Printf("B: 0x%02X | C: 0x%02X\n", d.reg.B, d.reg.C)
+	fmt.Printf("D: 0x%02X | E: 0x%02X\n", d.reg.D, d.reg.E)
+	fmt.Printf("H: 0x%02X | L: 0x%02X\n", d.reg.H, d.reg.L)
+	fmt.Printf("SP: 0x%04X\n", d.reg.SP)
+	fmt.Printf("PC: 0x%04X\n", d.reg.PC)
+	fmt.Printf("ROM bank: 0x%02X | RAM bank: 0x%02X\n", busBank(d.mem, 0x4000), busBank(d.mem, 0xA000))
+
+	fmt.Printf("Z N H C\n")
+	var z uint8 = 0
+	if d.reg.IsFlagSet(zeroFlag) {
+		z = 1
+	}
+	var n uint8 = 0
+	if d.reg.IsFlagSet(subtractFlag) {
+		n = 1
+	}
+	var h uint8 = 0
+	if d.reg.IsFlagSet(halfCarryFlag) {
+		h = 1
+	}
+	var c uint8 = 0
+	if d.reg.IsFlagSet(carryFlag) {
+		c = 1
+	}
+	fmt.Printf("%1b %1b %1b %1b\n", z, n, h, c)
+}
+
+// readInput reads the next command. At the end of the input the debugger
+// is disabled and the emulation continues.
+func (d *Debugger) readInput() string {
+	if d.input == nil {
+		d.input = bufio.NewScanner(os.Stdin)
+	}
+	if !d.input.Scan() {
+		d.Enabled = false
+		return "continue"
+	}
+	return d.input.Text()
+}
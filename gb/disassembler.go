@@ -0,0 +1,175 @@
+package gb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operands of the op code table, replaced by the immediate values:
+// d8 and d16 are data, a8 is the low byte of an address in 0xFF00-0xFFFF,
+// a16 is an address, r8 a relative jump target and s8 a signed offset.
+var disasmTable = [256]string{
+	"NOP", "LD BC,d16", "LD (BC),A", "INC BC", "INC B", "DEC B", "LD B,d8", "RLCA",
+	"LD (a16),SP", "ADD HL,BC", "LD A,(BC)", "DEC BC", "INC C", "DEC C", "LD C,d8", "RRCA",
+	"STOP", "LD DE,d16", "LD (DE),A", "INC DE", "INC D", "DEC D", "LD D,d8", "RLA",
+	"JR r8", "ADD HL,DE", "LD A,(DE)", "DEC DE", "INC E", "DEC E", "LD E,d8", "RRA",
+	"JR NZ,r8", "LD HL,d16", "LD (HL+),A", "INC HL", "INC H", "DEC H", "LD H,d8", "DAA",
+	"JR Z,r8", "ADD HL,HL", "LD A,(HL+)", "DEC HL", "INC L", "DEC L", "LD L,d8", "CPL",
+	"JR NC,r8", "LD SP,d16", "LD (HL-),A", "INC SP", "INC (HL)", "DEC (HL)", "LD (HL),d8", "SCF",
+	"JR C,r8", "ADD HL,SP", "LD A,(HL-)", "DEC SP", "INC A", "DEC A", "LD A,d8", "CCF",
+	0xC0: "RET NZ", "POP BC", "JP NZ,a16", "JP a16", "CALL NZ,a16", "PUSH BC", "ADD A,d8", "RST $00",
+	"RET Z", "RET", "JP Z,a16", "", "CALL Z,a16", "CALL a16", "ADC A,d8", "RST $08",
+	"RET NC", "POP DE", "JP NC,a16", "", "CALL NC,a16", "PUSH DE", "SUB d8", "RST $10",
+	"RET C", "RETI", "JP C,a16", "", "CALL C,a16", "", "SBC A,d8", "RST $18",
+	"LDH (a8),A", "POP HL", "LD (C),A", "", "", "PUSH HL", "AND d8", "RST $20",
+	"ADD SP,s8", "JP HL", "LD (a16),A", "", "", "", "XOR d8", "RST $28",
+	"LDH A,(a8)", "POP AF", "LD A,(C)", "DI", "", "PUSH AF", "OR d8", "RST $30",
+	"LD HL,SP+s8", "LD SP,HL", "LD A,(a16)", "EI", "", "", "CP d8", "RST $38",
+}
+
+var disasmRegisters = [8]string{"B", "C", "D", "E", "H", "L", "(HL)", "A"}
+
+func init() {
+	// 0x40-0xBF are regular: loads between registers and the ALU operations.
+	alu := [8]string{"ADD A,", "ADC A,", "SUB ", "SBC A,", "AND ", "XOR ", "OR ", "CP "}
+	for op := 0x40; op < 0xC0; op += 1 {
+		src := disasmRegisters[op&0x07]
+		if op < 0x80 {
+			disasmTable[op] = "LD " + disasmRegisters[(op>>3)&0x07] + "," + src
+		} else {
+			disasmTable[op] = alu[(op>>3)&0x07] + src
+		}
+	}
+	disasmTable[0x76] = "HALT"
+}
+
+// disasmCB returns the instruction with the 0xCB prefix.
+func disasmCB(op uint8) string {
+	reg := disasmRegisters[op&0x07]
+	if op < 0x40 {
+		shifts := [8]string{"RLC", "RRC", "RL", "RR", "SLA", "SRA", "SWAP", "SRL"}
+		return shifts[op>>3] + " " + reg
+	}
+	bitOps := [4]string{"", "BIT", "RES", "SET"}
+	return fmt.Sprintf("%v %v,%v", bitOps[op>>6], (op>>3)&0x07, reg)
+}
+
+// Instr is an instruction decoded by Disassemble.
+type Instr struct {
+	Addr  uint16
+	Bytes []byte
+	// Target is the address a jump, call or RST transfers control to if
+	// HasTarget is set.
+	Target    uint16
+	HasTarget bool
+	// Illegal is set for op codes that do not exist on the SM83. They are
+	// shown as data byte.
+	Illegal bool
+	// text is the instruction with %s in place of an address operand.
+	text    string
+	operand uint16
+}
+
+// Len returns the length of the instruction in bytes.
+func (i Instr) Len() int {
+	return len(i.Bytes)
+}
+
+// String returns the instruction in assembler syntax, e.g. "JR NZ,$0150".
+func (i Instr) String() string {
+	return i.Format(nil)
+}
+
+// Format returns the instruction in assembler syntax. Address operands are
+// replaced by the name label returns for them, if any.
+func (i Instr) Format(label func(addr uint16) string) string {
+	if !strings.Contains(i.text, "%s") {
+		return i.text
+	}
+	operand := fmt.Sprintf("$%04X", i.operand)
+	if label != nil {
+		if name := label(i.operand); name != "" {
+			operand = name
+		}
+	}
+	return fmt.Sprintf(i.text, operand)
+}
+
+// Disassemble decodes the instruction at addr. read returns the byte at an
+// address, so any memory (or ROM bank) can be disassembled.
+func Disassemble(read func(addr uint16) uint8, addr uint16) Instr {
+	in := Instr{Addr: addr, Bytes: []byte{read(addr)}}
+	op := in.Bytes[0]
+
+	if op == uint8(opCodeExt) {
+		in.Bytes = append(in.Bytes, read(addr+1))
+		in.text = disasmCB(in.Bytes[1])
+		return in
+	}
+	text := disasmTable[op]
+	if text == "" {
+		in.Illegal = true
+		in.text = fmt.Sprintf("DB $%02X", op)
+		return in
+	}
+	if op == 0x10 {
+		// STOP is followed by a byte that is ignored.
+		in.Bytes = append(in.Bytes, read(addr+1))
+	}
+
+	imm8 := func() uint8 {
+		in.Bytes = append(in.Bytes, read(addr+uint16(len(in.Bytes))))
+		return in.Bytes[len(in.Bytes)-1]
+	}
+	switch {
+	case strings.Contains(text, "d16"):
+		lo := imm8()
+		text = strings.Replace(text, "d16", fmt.Sprintf("$%04X", uint16(imm8())<<8|uint16(lo)), 1)
+	case strings.Contains(text, "a16"):
+		lo := imm8()
+		in.operand = uint16(imm8())<<8 | uint16(lo)
+		text = strings.Replace(text, "a16", "%s", 1)
+		if strings.HasPrefix(text, "JP") || strings.HasPrefix(text, "CALL") {
+			in.Target, in.HasTarget = in.operand, true
+		}
+	case strings.Contains(text, "d8"):
+		text = strings.Replace(text, "d8", fmt.Sprintf("$%02X", imm8()), 1)
+	case strings.Contains(text, "a8"):
+		text = strings.Replace(text, "a8", fmt.Sprintf("$FF%02X", imm8()), 1)
+	case strings.Contains(text, "r8"):
+		offset := int8(imm8())
+		in.operand = addr + 2 + uint16(offset)
+		in.Target, in.HasTarget = in.operand, true
+		text = strings.Replace(text, "r8", "%s", 1)
+	case strings.Contains(text, "SP+s8"):
+		offset := int8(imm8())
+		if offset < 0 {
+			text = strings.Replace(text, "+s8", fmt.Sprintf("-%d", -int(offset)), 1)
+		} else {
+			text = strings.Replace(text, "s8", fmt.Sprintf("%d", offset), 1)
+		}
+	case strings.Contains(text, "s8"):
+		text = strings.Replace(text, "s8", fmt.Sprintf("%d", int8(imm8())), 1)
+	case strings.HasPrefix(text, "RST"):
+		in.Target, in.HasTarget = uint16(op&0x38), true
+	}
+	in.text = text
+	return in
+}
+
+// instrLength returns the length in bytes of the instruction with the op
+// code op. It does not depend on the operands.
+func instrLength(op uint8) int {
+	return Disassemble(func(addr uint16) uint8 { return op }, 0).Len()
+}
+
+// DisassembleN decodes n consecutive instructions starting at addr.
+func DisassembleN(read func(addr uint16) uint8, addr uint16, n int) []Instr {
+	instrs := make([]Instr, 0, n)
+	for i := 0; i < n; i += 1 {
+		in := Disassemble(read, addr)
+		instrs = append(instrs, in)
+		addr += uint16(in.Len())
+	}
+	return instrs
+}
@@ -0,0 +1,94 @@
+package gb
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		name string
+		code []byte
+		want string
+		// target is the jump target or -1 if there is none.
+		target  int
+		illegal bool
+	}{
+		{"no operand", []byte{0x00}, "NOP", -1, false},
+		{"d16", []byte{0x01, 0x34, 0x12}, "LD BC,$1234", -1, false},
+		{"a16 jump", []byte{0xC3, 0x50, 0x01}, "JP $0150", 0x0150, false},
+		{"a16 call", []byte{0xDC, 0x00, 0x02}, "CALL C,$0200", 0x0200, false},
+		{"a16 load", []byte{0xEA, 0x00, 0xC0}, "LD ($C000),A", -1, false},
+		{"a16 store SP", []byte{0x08, 0xFE, 0xFF}, "LD ($FFFE),SP", -1, false},
+		{"d8", []byte{0x3E, 0x42}, "LD A,$42", -1, false},
+		{"a8", []byte{0xE0, 0x44}, "LDH ($FF44),A", -1, false},
+		{"r8 backward", []byte{0x20, 0xFE}, "JR NZ,$0150", 0x0150, false},
+		{"r8 forward", []byte{0x18, 0x05}, "JR $0157", 0x0157, false},
+		{"SP+s8 negative", []byte{0xF8, 0xFE}, "LD HL,SP-2", -1, false},
+		{"SP+s8 positive", []byte{0xF8, 0x05}, "LD HL,SP+5", -1, false},
+		{"s8", []byte{0xE8, 0xFC}, "ADD SP,-4", -1, false},
+		{"RST", []byte{0xFF}, "RST $38", 0x0038, false},
+		{"return", []byte{0xD9}, "RETI", -1, false},
+		{"STOP", []byte{0x10, 0x00}, "STOP", -1, false},
+		{"register load", []byte{0x41}, "LD B,C", -1, false},
+		{"register load (HL)", []byte{0x7E}, "LD A,(HL)", -1, false},
+		{"HALT", []byte{0x76}, "HALT", -1, false},
+		{"ALU (HL)", []byte{0x86}, "ADD A,(HL)", -1, false},
+		{"ALU register", []byte{0xA8}, "XOR B", -1, false},
+		{"ALU compare", []byte{0xBF}, "CP A", -1, false},
+		{"CB rotate", []byte{0xCB, 0x00}, "RLC B", -1, false},
+		{"CB swap", []byte{0xCB, 0x37}, "SWAP A", -1, false},
+		{"CB bit", []byte{0xCB, 0x7C}, "BIT 7,H", -1, false},
+		{"CB reset", []byte{0xCB, 0x86}, "RES 0,(HL)", -1, false},
+		{"CB set", []byte{0xCB, 0xFF}, "SET 7,A", -1, false},
+		{"illegal", []byte{0xD3}, "DB $D3", -1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mem [0x10000]uint8
+			copy(mem[0x0150:], test.code)
+			// Trailing bytes must not be part of the instruction.
+			mem[0x0150+len(test.code)] = 0xAA
+			read := func(addr uint16) uint8 { return mem[addr] }
+
+			in := Disassemble(read, 0x0150)
+			if got := in.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if in.Len() != len(test.code) {
+				t.Errorf("length: got %v, want %v", in.Len(), len(test.code))
+			}
+			target := -1
+			if in.HasTarget {
+				target = int(in.Target)
+			}
+			if target != test.target {
+				t.Errorf("target: got %v, want %v", target, test.target)
+			}
+			if in.Illegal != test.illegal {
+				t.Errorf("illegal: got %v, want %v", in.Illegal, test.illegal)
+			}
+		})
+	}
+}
+
+func TestDisassembleN(t *testing.T) {
+	var mem [0x10000]uint8
+	copy(mem[0x0100:], []byte{0xCD, 0x50, 0x01, 0x18, 0xFB, 0xCB, 0x7C})
+	read := func(addr uint16) uint8 { return mem[addr] }
+	label := func(addr uint16) string {
+		if addr == 0x0150 {
+			return "Main"
+		}
+		return ""
+	}
+
+	want := []string{"CALL Main", "JR $0100", "BIT 7,H"}
+	instrs := DisassembleN(read, 0x0100, len(want))
+	for i, in := range instrs {
+		if got := in.Format(label); got != want[i] {
+			t.Errorf("instruction %v: got %q, want %q", i, got, want[i])
+		}
+	}
+	if last := instrs[len(instrs)-1]; last.Addr != 0x0105 {
+		t.Errorf("address of the last instruction: got 0x%04X, want 0x0105", last.Addr)
+	}
+}
@@ -0,0 +1,286 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// CyclesPerFrame is the number of clock cycles the DMG needs to draw one frame
+// (154 lines of 456 cycles each), which makes for a frame rate of about 59.7 Hz.
+const CyclesPerFrame = 70224
+
+// ClockRate is the CPU clock rate of the DMG in Hz.
+const ClockRate = 4194304
+
+// Emulator wires the CPU, memory, cartridge and peripherals together and
+// provides the entry points to drive the emulation step by step.
+type Emulator struct {
+	cpu    *CPU
+	mem    *Memory
+	cart   *Cartridge
+	joypad *Joypad
+	serial *Serial
+	dbg    *Debugger
+	log    Logger
+	frame  Framebuffer
+	// recorder captures the frames if a recording is running.
+	recorder *Recorder
+	// rewinder keeps the history of the emulation if rewinding is enabled.
+	rewinder *Rewinder
+	// movie is the movie being recorded or played back (moviePlayback set)
+	// since frame movieStart.
+	movie         *Movie
+	moviePlayback bool
+	movieStart    uint64
+	// withBootROM is set if the emulation starts with the boot ROM.
+	withBootROM     bool
+	bootROMChecksum uint32
+	// illegalOpcodePolicy determines how an illegal op code is handled.
+	illegalOpcodePolicy IllegalOpcodePolicy
+	// illegalOpcodeBroken is set after breaking before an illegal op code, so
+	// the CPU locks up if it is executed again after continuing.
+	illegalOpcodeBroken bool
+	// cycles is the total number of clock cycles executed so far.
+	cycles uint64
+	// frameCycles is the number of clock cycles executed in the current frame.
+	frameCycles uint
+	// frames is the number of frames completed so far.
+	frames uint64
+}
+
+// NewEmulator creates an emulator for the given cartridge ROM. If bootROM is
+// nil the boot sequence is skipped and execution starts at the cartridge
+// entry point 0x0100 with the register values the boot ROM would leave behind.
+func NewEmulator(bootROM []byte, cartROM []byte) (*Emulator, error) {
+	var boot *[BootROMSize]byte
+	if bootROM != nil {
+		if len(bootROM) != BootROMSize {
+			return nil, fmt.Errorf(
+				"boot ROM has size %vB but must be %vB", len(bootROM), BootROMSize)
+		}
+		boot = &[BootROMSize]byte{}
+		copy(boot[:], bootROM)
+	}
+
+	cart, err := NewCartridge(cartROM)
+	if err != nil {
+		return nil, err
+	}
+
+	joypad := NewJoypad()
+	serial := NewSerial()
+	mem := NewMemory(boot, cart, joypad, serial)
+	cpu := NewCPU(mem)
+	cpu.reset()
+	if boot == nil {
+		cpu.skipBoot()
+	}
+
+	e := &Emulator{
+		cpu:    cpu,
+		mem:    mem,
+		cart:   cart,
+		joypad: joypad,
+		serial: serial,
+		log:    nopLogger{},
+	}
+	if boot != nil {
+		e.withBootROM = true
+		e.bootROMChecksum = crc32.ChecksumIEEE(boot[:])
+	}
+	e.dbg = NewDebugger(mem, &cpu.reg, &e.frame)
+	// The CPU accesses memory through the debugger to trigger watchpoints.
+	cpu.mem = e.dbg.watch
+	e.dbg.calls = &cpu.calls
+	return e, nil
+}
+
+// SetLogger sets the logger that receives the diagnostic messages of all
+// subsystems. By default nothing is logged.
+func (e *Emulator) SetLogger(log Logger) {
+	if log == nil {
+		log = nopLogger{}
+	}
+	e.log = log
+	e.mem.log = log
+	e.mem.io.log = log
+	e.cart.log = log
+	log.Logf(LogCart, LogInfo, "Cartridge %q (type 0x%02X, %v ROM banks, %vB RAM)",
+		e.cart.Title(), e.cart.rom[cartTypeAddr], len(e.cart.rom)/romBankSize, len(e.cart.ram))
+}
+
+// EnableDebugger enables or disables the interactive debugger on stdin.
+func (e *Emulator) EnableDebugger(enabled bool) {
+	e.dbg.Enabled = enabled
+}
+
+// WrapBus replaces the bus of the CPU and the debugger with the bus returned
+// by wrap, which is called with the current bus. This allows to record or
+// instrument all memory accesses. Watchpoints stay on top of the new bus.
+func (e *Emulator) WrapBus(wrap func(Bus) Bus) {
+	bus := wrap(e.dbg.mem)
+	e.dbg.mem = bus
+	e.dbg.watch.bus = bus
+}
+
+// SetSymbols sets the labels the debugger and the trace show for addresses.
+// The debugger accepts them in place of addresses.
+func (e *Emulator) SetSymbols(s *Symbols) {
+	e.dbg.symbols = s
+	e.cpu.symbols = s
+}
+
+// Debugger returns the debugger of the emulator.
+func (e *Emulator) Debugger() *Debugger {
+	return e.dbg
+}
+
+// EnableTrace enables or disables printing every executed instruction on stdout.
+func (e *Emulator) EnableTrace(enabled bool) {
+	e.cpu.trace = enabled
+}
+
+// EnableDebugTrap makes the emulation stop with an ErrDebugTrap after each
+// LD B,B instruction, which test ROMs use as software breakpoint.
+func (e *Emulator) EnableDebugTrap(enabled bool) {
+	e.cpu.debugTrap = enabled
+}
+
+// SetIllegalOpcodePolicy sets how an illegal op code is handled.
+// The default is to lock up the CPU like the hardware does.
+func (e *Emulator) SetIllegalOpcodePolicy(policy IllegalOpcodePolicy) {
+	e.illegalOpcodePolicy = policy
+}
+
+// StepInstruction executes a single CPU instruction.
+func (e *Emulator) StepInstruction() error {
+	e.dbg.Cycle()
+
+	cycles, err := e.cpu.Step()
+	e.addCycles(cycles)
+
+	var illegal ErrIllegalOpcode
+	if !errors.As(err, &illegal) {
+		e.illegalOpcodeBroken = false
+		return err
+	}
+	policy := e.illegalOpcodePolicy
+	if policy == IllegalOpcodeBreak && e.illegalOpcodeBroken {
+		policy = IllegalOpcodeLockUp
+	}
+	switch policy {
+	case IllegalOpcodeLockUp:
+		e.log.Logf(LogCPU, LogWarn, "CPU locked up: %v", illegal)
+		e.cpu.locked = true
+		e.illegalOpcodeBroken = false
+		err = nil
+	case IllegalOpcodeBreak:
+		e.dbg.Break(illegal.Error())
+		e.illegalOpcodeBroken = true
+		err = nil
+	}
+	return err
+}
+
+// addCycles advances the clock and completes the frame when it is due.
+func (e *Emulator) addCycles(cycles uint) {
+	e.cycles += uint64(cycles)
+	e.frameCycles += cycles
+	if e.frameCycles >= CyclesPerFrame {
+		e.frameCycles -= CyclesPerFrame
+		e.frames += 1
+		if e.rewinder != nil && e.rewinder.replaying {
+			return
+		}
+		if e.recorder != nil {
+			e.recorder.AddFrame(e.frames, &e.frame)
+		}
+		if e.rewinder != nil {
+			e.rewinder.frameCompleted(e)
+		}
+		if e.movie != nil {
+			e.updateMovie()
+		}
+	}
+}
+
+// RunCycles executes instructions until at least n clock cycles have passed.
+func (e *Emulator) RunCycles(n uint) error {
+	target := e.cycles + uint64(n)
+	for e.cycles < target {
+		if err := e.StepInstruction(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunFrame executes instructions until the current frame is complete.
+func (e *Emulator) RunFrame() error {
+	for frame := e.frames; e.frames == frame; {
+		if err := e.StepInstruction(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Frames returns the number of frames completed so far.
+func (e *Emulator) Frames() uint64 {
+	return e.frames
+}
+
+// SetRecorder sets the recorder that captures the completed frames.
+// A nil recorder stops capturing.
+func (e *Emulator) SetRecorder(r *Recorder) {
+	e.recorder = r
+	e.dbg.recorder = r
+}
+
+// Cycles returns the total number of clock cycles executed so far.
+func (e *Emulator) Cycles() uint64 {
+	return e.cycles
+}
+
+// Framebuffer returns the last frame drawn by the LCD. The PPU is not
+// emulated yet, so the framebuffer stays blank.
+func (e *Emulator) Framebuffer() *Framebuffer {
+	return &e.frame
+}
+
+// AudioSamples returns the interleaved stereo samples generated since the
+// last call. The APU is not emulated yet, so there are never any samples.
+func (e *Emulator) AudioSamples() []int16 {
+	return nil
+}
+
+// SetSerialOutput sets the writer that receives every byte sent over the serial port.
+func (e *Emulator) SetSerialOutput(w io.Writer) {
+	e.serial.SetOutput(w)
+}
+
+// Registers returns the current values of the CPU registers.
+func (e *Emulator) Registers() Registers {
+	return e.cpu.reg
+}
+
+// ReadMemory reads length bytes starting at start from the bus as the CPU
+// would see them. Unmapped addresses read as 0xFF and cause an error.
+func (e *Emulator) ReadMemory(start uint16, length int) ([]byte, error) {
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = e.mem.Read8(start + uint16(i))
+	}
+	return data, e.mem.Fault()
+}
+
+// SetInput sets the buttons that are currently held down. It has no effect
+// while a movie is played back.
+func (e *Emulator) SetInput(buttons Buttons) {
+	if e.MoviePlaying() {
+		return
+	}
+	e.joypad.SetPressed(buttons)
+}
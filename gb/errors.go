@@ -0,0 +1,78 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnmappedAddress is returned if memory is accessed at an address
+// that no memory or device is mapped to.
+type ErrUnmappedAddress struct {
+	Addr  uint16
+	Write bool
+}
+
+func (e ErrUnmappedAddress) Error() string {
+	if e.Write {
+		return fmt.Sprintf("write to unmapped memory address 0x%04X", e.Addr)
+	}
+	return fmt.Sprintf("read from unmapped memory address 0x%04X", e.Addr)
+}
+
+// ErrIllegalOpcode is returned if the CPU fetches one of the op codes that
+// do not exist on the SM83 (0xD3, 0xDB, 0xDD, 0xE3, 0xE4, 0xEB, 0xEC, 0xED,
+// 0xF4, 0xFC and 0xFD).
+type ErrIllegalOpcode struct {
+	Op uint16
+	PC uint16
+}
+
+func (e ErrIllegalOpcode) Error() string {
+	return fmt.Sprintf("illegal op code 0x%02X at address 0x%04X", e.Op, e.PC)
+}
+
+// ErrUnimplementedOpcode is returned if the CPU fetches a valid op code
+// that the emulator does not implement yet.
+type ErrUnimplementedOpcode struct {
+	Op uint16
+	PC uint16
+}
+
+func (e ErrUnimplementedOpcode) Error() string {
+	return fmt.Sprintf("unimplemented op code 0x%X at address 0x%04X", e.Op, e.PC)
+}
+
+// ErrDebugTrap is returned after the CPU executed LD B,B at address PC
+// while the debug trap is enabled.
+type ErrDebugTrap struct {
+	PC uint16
+}
+
+func (e ErrDebugTrap) Error() string {
+	return fmt.Sprintf("debug trap (LD B,B) at address 0x%04X", e.PC)
+}
+
+// IllegalOpcodePolicy determines what happens if the CPU fetches an illegal op code.
+type IllegalOpcodePolicy int
+
+const (
+	// IllegalOpcodeLockUp freezes the CPU like the hardware does.
+	IllegalOpcodeLockUp IllegalOpcodePolicy = iota
+	// IllegalOpcodeError stops the emulation with an ErrIllegalOpcode.
+	IllegalOpcodeError
+	// IllegalOpcodeBreak breaks into the debugger before the illegal op code.
+	// Continuing without changing PC locks up the CPU.
+	IllegalOpcodeBreak
+)
+
+// ErrInvalidState is returned if a save state can not be loaded.
+type ErrInvalidState struct {
+	Reason string
+}
+
+func (e ErrInvalidState) Error() string {
+	return fmt.Sprintf("invalid save state: %v", e.Reason)
+}
+
+// ErrNoHistory is returned if the emulation can not be rewound any further.
+var ErrNoHistory = errors.New("no earlier state to rewind to")
@@ -0,0 +1,389 @@
+package gb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Debugger expressions are C-like expressions over integers. Operands are
+// numbers (decimal, or hexadecimal with 0x or $ prefix), the registers A, F,
+// D, E, L, AF, BC, DE, HL, SP and PC, the flags Z, N, H and C (or ZF, NF, HF
+// and CF), BANK for the current ROM bank, symbols (their address) and memory
+// reads like [HL] or [0xFF44]. As H and C are the flags, the registers H and C
+// are read with HL>>8 and BC&0xFF. Comparisons and logical operators return
+// 1 or 0.
+
+// exprEnv is the machine state expressions are evaluated against.
+type exprEnv struct {
+	reg *Registers
+	mem Bus
+}
+
+type expr interface {
+	eval(env *exprEnv) (int, error)
+}
+
+type exprNumber int
+
+func (n exprNumber) eval(env *exprEnv) (int, error) {
+	return int(n), nil
+}
+
+type exprRegister string
+
+func (r exprRegister) eval(env *exprEnv) (int, error) {
+	reg := env.reg
+	switch r {
+	case "A":
+		return int(reg.A), nil
+	case "F":
+		return int(reg.F), nil
+	case "B":
+		return int(reg.B), nil
+	case "D":
+		return int(reg.D), nil
+	case "E":
+		return int(reg.E), nil
+	case "L":
+		return int(reg.L), nil
+	case "AF":
+		return int(reg.AF()), nil
+	case "BC":
+		return int(reg.BC()), nil
+	case "DE":
+		return int(reg.DE()), nil
+	case "HL":
+		return int(reg.HL()), nil
+	case "SP":
+		return int(reg.SP), nil
+	case "PC":
+		return int(reg.PC), nil
+	}
+	return 0, fmt.Errorf("unknown register %v", string(r))
+}
+
+type exprFlag uint8
+
+func (f exprFlag) eval(env *exprEnv) (int, error) {
+	return boolToInt(env.reg.IsFlagSet(uint8(f))), nil
+}
+
+type exprBank struct{}
+
+func (exprBank) eval(env *exprEnv) (int, error) {
+	if b, ok := env.mem.(Banker); ok {
+		return b.Bank(0x4000), nil
+	}
+	return 1, nil
+}
+
+// exprDeref reads the byte at the address its operand evaluates to.
+type exprDeref struct {
+	addr expr
+}
+
+func (d exprDeref) eval(env *exprEnv) (int, error) {
+	addr, err := d.addr.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	val := env.mem.Read8(uint16(addr))
+	if f, ok := env.mem.(Faulter); ok {
+		if err := f.Fault(); err != nil {
+			return 0, err
+		}
+	}
+	return int(val), nil
+}
+
+type exprUnary struct {
+	op string
+	x  expr
+}
+
+func (u exprUnary) eval(env *exprEnv) (int, error) {
+	x, err := u.x.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch u.op {
+	case "-":
+		return -x, nil
+	case "~":
+		return ^x, nil
+	}
+	return boolToInt(x == 0), nil
+}
+
+type exprBinary struct {
+	op   string
+	x, y expr
+}
+
+func (b exprBinary) eval(env *exprEnv) (int, error) {
+	x, err := b.x.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	// && and || only evaluate their right operand if needed.
+	if b.op == "&&" && x == 0 {
+		return 0, nil
+	}
+	if b.op == "||" && x != 0 {
+		return 1, nil
+	}
+	y, err := b.y.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case "&&", "||":
+		return boolToInt(y != 0), nil
+	case "|":
+		return x | y, nil
+	case "^":
+		return x ^ y, nil
+	case "&":
+		return x & y, nil
+	case "==":
+		return boolToInt(x == y), nil
+	case "!=":
+		return boolToInt(x != y), nil
+	case "<":
+		return boolToInt(x < y), nil
+	case "<=":
+		return boolToInt(x <= y), nil
+	case ">":
+		return boolToInt(x > y), nil
+	case ">=":
+		return boolToInt(x >= y), nil
+	case "<<":
+		return x << uint(y&63), nil
+	case ">>":
+		return x >> uint(y&63), nil
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+		if b.op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+	return 0, fmt.Errorf("unknown operator %v", b.op)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// binaryPrecedence is the precedence of the binary operators. Higher binds tighter.
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"|":  3,
+	"^":  4,
+	"&":  5,
+	"==": 6, "!=": 6,
+	"<": 7, "<=": 7, ">": 7, ">=": 7,
+	"<<": 8, ">>": 8,
+	"+": 9, "-": 9,
+	"*": 10, "/": 10, "%": 10,
+}
+
+var exprFlags = map[string]uint8{
+	"ZF": zeroFlag, "NF": subtractFlag, "HF": halfCarryFlag, "CF": carryFlag,
+	"Z": zeroFlag, "N": subtractFlag, "H": halfCarryFlag, "C": carryFlag,
+}
+
+var exprRegisters = map[string]bool{
+	"A": true, "F": true, "B": true, "D": true, "E": true, "L": true,
+	"AF": true, "BC": true, "DE": true, "HL": true, "SP": true, "PC": true,
+}
+
+// exprParser is a precedence climbing parser for debugger expressions.
+type exprParser struct {
+	tokens  []string
+	pos     int
+	symbols *Symbols
+}
+
+// parseExpr parses a debugger expression. symbols may be nil.
+func parseExpr(s string, symbols *Symbols) (expr, error) {
+	tokens, err := tokenizeExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, symbols: symbols}
+	e, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	p.pos += 1
+	return tok
+}
+
+func (p *exprParser) parseBinary(minPrec int) (expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		prec, ok := binaryPrecedence[op]
+		if !ok || prec < minPrec {
+			return x, nil
+		}
+		p.next()
+		y, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = exprBinary{op, x, y}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	switch op := p.peek(); op {
+	case "-", "!", "~":
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprUnary{op, x}, nil
+	}
+	return p.parseOperand()
+}
+
+func (p *exprParser) parseOperand() (expr, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, errors.New("unexpected end of expression")
+	case tok == "(" || tok == "[":
+		x, err := p.parseBinary(1)
+		if err != nil {
+			return nil, err
+		}
+		closing := ")"
+		if tok == "[" {
+			closing = "]"
+		}
+		if p.next() != closing {
+			return nil, fmt.Errorf("missing %q", closing)
+		}
+		if tok == "[" {
+			return exprDeref{x}, nil
+		}
+		return x, nil
+	case isDigit(tok[0]) || tok[0] == '$':
+		n, err := parseNumber(tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok)
+		}
+		return exprNumber(n), nil
+	case isIdentStart(tok[0]):
+		name := strings.ToUpper(tok)
+		if exprRegisters[name] {
+			return exprRegister(name), nil
+		}
+		if flag, ok := exprFlags[name]; ok {
+			return exprFlag(flag), nil
+		}
+		if name == "BANK" {
+			return exprBank{}, nil
+		}
+		if sym, ok := p.symbols.Lookup(tok); ok {
+			return exprNumber(sym.Addr), nil
+		}
+		return nil, fmt.Errorf("unknown name %q", tok)
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
+
+// parseNumber parses a decimal number or a hexadecimal one with 0x or $ prefix.
+func parseNumber(s string) (int, error) {
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "0x") {
+		n, err := strconv.ParseUint(lower[2:], 16, 32)
+		return int(n), err
+	}
+	if strings.HasPrefix(lower, "$") {
+		n, err := strconv.ParseUint(lower[1:], 16, 32)
+		return int(n), err
+	}
+	n, err := strconv.ParseUint(lower, 10, 32)
+	return int(n), err
+}
+
+var twoCharOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<<", ">>"}
+
+func tokenizeExpr(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i += 1
+		case isDigit(c) || c == '$' || isIdentStart(c):
+			j := i + 1
+			// Local labels like Main.loop contain a dot.
+			for j < len(s) && (isIdentStart(s[j]) || isDigit(s[j]) || s[j] == '.') {
+				j += 1
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			op := ""
+			for _, two := range twoCharOperators {
+				if strings.HasPrefix(s[i:], two) {
+					op = two
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("+-*/%&|^!~<>()[]", rune(c)) {
+					return nil, fmt.Errorf("unexpected character %q", c)
+				}
+				op = string(c)
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,100 @@
+package gb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpressions(t *testing.T) {
+	symbols, err := ParseSymbols(strings.NewReader("00:0150 Main\n00:0160 Main.loop\n"))
+	if err != nil {
+		t.Fatalf("Could not parse symbols: %v", err)
+	}
+	bus := &flatBus{}
+	bus.mem[0xC000] = 0x99
+	env := &exprEnv{
+		reg: &Registers{
+			A: 0x12, B: 0x34, C: 0x56, H: 0xC0, L: 0x00,
+			F: zeroFlag | carryFlag, SP: 0xFFFE, PC: 0x0150,
+		},
+		mem: bus,
+	}
+
+	tests := []struct {
+		expr string
+		want int
+		// err is a part of the expected error message.
+		err string
+	}{
+		// Precedence and associativity
+		{expr: "1 + 2 * 3", want: 7},
+		{expr: "(1 + 2) * 3", want: 9},
+		{expr: "10 - 4 - 3", want: 3},
+		{expr: "1 << 2 + 1", want: 8},
+		{expr: "6 & 3 == 2", want: 0},
+		{expr: "1 | 6 ^ 3 & 2", want: 5},
+		{expr: "1 < 2 == 1", want: 1},
+		{expr: "1 || 0 && 0", want: 1},
+		{expr: "-2 * -3", want: 6},
+		{expr: "!0 + ~0", want: 0},
+		{expr: "7 % 4 * 2", want: 6},
+		// Operands
+		{expr: "$FF + 0x10", want: 0x10F},
+		{expr: "a", want: 0x12},
+		{expr: "HL", want: 0xC000},
+		{expr: "[HL]", want: 0x99},
+		{expr: "[HL + 1]", want: 0x00},
+		{expr: "BC & 0xFF", want: 0x56},
+		{expr: "HL >> 8", want: 0xC0},
+		{expr: "ZF && CF && !NF && !HF", want: 1},
+		{expr: "Z + N + H + C", want: 2},
+		{expr: "Main.loop - Main", want: 0x10},
+		{expr: "BANK", want: 1},
+		// Errors
+		{expr: "1 / 0", err: "division by zero"},
+		{expr: "1 +", err: "unexpected end"},
+		{expr: "(1", err: `missing ")"`},
+		{expr: "[1", err: `missing "]"`},
+		{expr: "1 2", err: `unexpected "2"`},
+		{expr: "1 @ 2", err: "unexpected character"},
+		{expr: "Unknown", err: `unknown name "Unknown"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			e, err := parseExpr(test.expr, symbols)
+			var got int
+			if err == nil {
+				got, err = e.eval(env)
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Could not evaluate: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConditionErrorStops(t *testing.T) {
+	reg := &Registers{A: 0x12, PC: 0x0150}
+	d := NewDebugger(&flatBus{}, reg, &Framebuffer{})
+	id := d.addBreakpoint(location{bank: -1, addr: 0x0150}, "1 / (A - 0x12)")
+	if id == 0 {
+		t.Fatalf("Could not add breakpoint")
+	}
+
+	if hit, _ := d.shouldBreakAt(0x0150); !hit {
+		t.Errorf("Breakpoint with failing condition not hit")
+	}
+	if !d.breaks[id].enabled {
+		t.Errorf("Breakpoint disabled")
+	}
+}
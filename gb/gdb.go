@@ -0,0 +1,504 @@
+package gb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Signals reported to GDB in stop replies.
+const gdbSIGINT = 2
+const gdbSIGTRAP = 5
+
+// gdbTargetXML describes the registers of the SM83 to GDB. They are
+// transferred in this order, 16 bits each in little-endian byte order.
+// GDB has no SM83 architecture of its own, so a build with Z80 support
+// (like gdb-multiarch) is needed to disassemble.
+const gdbTargetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <architecture>gbz80</architecture>
+  <feature name="org.gnu.gdb.z80.cpu">
+    <reg name="af" bitsize="16" type="uint16"/>
+    <reg name="bc" bitsize="16" type="uint16"/>
+    <reg name="de" bitsize="16" type="uint16"/>
+    <reg name="hl" bitsize="16" type="data_ptr"/>
+    <reg name="sp" bitsize="16" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+const gdbRegisterCount = 6
+
+// gdbStub lets GDB control the debugger over the GDB Remote Serial Protocol
+// instead of the prompt on stdin. It serves a single connection. When GDB
+// detaches or the connection is closed, the emulation continues without
+// debugger.
+type gdbStub struct {
+	d        *Debugger
+	listener net.Listener
+	conn     net.Conn
+	// packets receives the packets read from conn. It is closed when the
+	// connection is.
+	packets chan gdbPacket
+	// done is closed when the stub stops reading packets.
+	done chan struct{}
+	// interrupt is set to 1 when GDB sends an interrupt (Ctrl+C).
+	interrupt int32
+	noAck     bool
+	// running is set while the emulation runs on behalf of GDB, which waits
+	// for a stop reply.
+	running bool
+	// signal is the reason of the last stop.
+	signal int
+	// breaks and watches map GDB's breakpoints and watchpoints to the ids
+	// they have in the debugger.
+	breaks  map[uint16]uint
+	watches map[gdbWatch]uint
+}
+
+// gdbPacket is a packet received from GDB. valid is not set if its
+// checksum is wrong.
+type gdbPacket struct {
+	data  string
+	valid bool
+}
+
+type gdbWatch struct {
+	kind   watchKind
+	addr   uint16
+	length uint16
+}
+
+// ServeGDB lets GDB control the emulation over the Remote Serial Protocol on
+// the connections accepted from l. The emulation waits for GDB to connect
+// before the first instruction.
+func (e *Emulator) ServeGDB(l net.Listener) {
+	e.dbg.frontend = &gdbStub{
+		d:        e.dbg,
+		listener: l,
+		breaks:   make(map[uint16]uint),
+		watches:  make(map[gdbWatch]uint),
+	}
+	e.dbg.Enabled = true
+	e.dbg.stepMode = true
+}
+
+func (s *gdbStub) interrupted() bool {
+	return atomic.LoadInt32(&s.interrupt) == 1
+}
+
+func (s *gdbStub) halted() {
+	signal := gdbSIGTRAP
+	if atomic.SwapInt32(&s.interrupt, 0) == 1 {
+		signal = gdbSIGINT
+	}
+	if s.conn == nil {
+		if !s.accept() {
+			s.detach()
+			return
+		}
+	} else if s.running {
+		s.send(s.stopReply(signal))
+	}
+	s.signal = signal
+	s.running = false
+
+	for p := range s.packets {
+		if !s.noAck {
+			if !p.valid {
+				s.conn.Write([]byte("-"))
+				continue
+			}
+			s.conn.Write([]byte("+"))
+		}
+		if s.handle(p.data) {
+			return
+		}
+	}
+	fmt.Println("GDB disconnected")
+	s.detach()
+}
+
+// accept waits for GDB to connect.
+func (s *gdbStub) accept() bool {
+	fmt.Printf("Waiting for GDB to connect to %v\n", s.listener.Addr())
+	conn, err := s.listener.Accept()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return false
+	}
+	s.conn = conn
+	s.noAck = false
+	s.packets = make(chan gdbPacket)
+	s.done = make(chan struct{})
+	go s.read(conn, s.packets, s.done)
+	return true
+}
+
+// detach removes the breakpoints and watchpoints of GDB, closes the
+// connection and disables the debugger.
+func (s *gdbStub) detach() {
+	for addr, id := range s.breaks {
+		s.d.deleteBreakpoint(id)
+		delete(s.breaks, addr)
+	}
+	for w, id := range s.watches {
+		s.d.deleteWatchpoint(id)
+		delete(s.watches, w)
+	}
+	if s.conn != nil {
+		close(s.done)
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.running = false
+	s.d.Enabled = false
+	s.d.stepMode = false
+}
+
+// read reads packets from conn until it is closed. Interrupts are flagged
+// right away, so they reach the running emulation.
+func (s *gdbStub) read(conn net.Conn, packets chan<- gdbPacket, done <-chan struct{}) {
+	defer close(packets)
+	r := bufio.NewReader(conn)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		// Acknowledgements (+ and -) need no handling as packets are
+		// never retransmitted.
+		switch b {
+		case 0x03:
+			atomic.StoreInt32(&s.interrupt, 1)
+		case '$':
+			data, err := r.ReadString('#')
+			if err != nil {
+				return
+			}
+			data = data[:len(data)-1]
+			var sum [2]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return
+			}
+			checksum, err := strconv.ParseUint(string(sum[:]), 16, 8)
+			p := gdbPacket{data, err == nil && uint8(checksum) == gdbChecksum(data)}
+			select {
+			case packets <- p:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+func (s *gdbStub) send(data string) {
+	fmt.Fprintf(s.conn, "$%v#%02x", data, gdbChecksum(data))
+}
+
+func gdbChecksum(data string) uint8 {
+	var sum uint8
+	for i := 0; i < len(data); i += 1 {
+		sum += data[i]
+	}
+	return sum
+}
+
+// handle answers a packet and returns whether the emulation continues.
+func (s *gdbStub) handle(p string) bool {
+	if p == "" {
+		s.send("")
+		return false
+	}
+	switch cmd, args := p[0], p[1:]; cmd {
+	case '?':
+		s.send(s.stopReply(s.signal))
+	case 'g':
+		s.send(s.readRegisters())
+	case 'G':
+		s.send(s.writeRegisters(args))
+	case 'p':
+		n, err := strconv.ParseUint(args, 16, 8)
+		if err != nil || n >= gdbRegisterCount {
+			s.send("E01")
+			return false
+		}
+		s.send(s.readRegisters()[n*4 : n*4+4])
+	case 'P':
+		s.send(s.writeRegister(args))
+	case 'm':
+		s.send(s.readMemory(args))
+	case 'M':
+		s.send(s.writeMemory(args))
+	case 'Z', 'z':
+		s.send(s.breakpoint(cmd == 'Z', args))
+	case 'c', 's':
+		if args != "" {
+			addr, err := strconv.ParseUint(args, 16, 16)
+			if err != nil {
+				s.send("E01")
+				return false
+			}
+			s.d.reg.PC = uint16(addr)
+		}
+		s.d.stepMode = cmd == 's'
+		s.running = true
+		return true
+	case 'D':
+		s.send("OK")
+		fmt.Println("GDB detached")
+		s.detach()
+		return true
+	case 'k':
+		fmt.Println("Exiting program...")
+		os.Exit(0)
+	case 'H':
+		// There is only one thread.
+		s.send("OK")
+	case 'q', 'Q':
+		s.query(p)
+	default:
+		s.send("")
+	}
+	return false
+}
+
+func (s *gdbStub) query(p string) {
+	const xferPrefix = "qXfer:features:read:target.xml:"
+	switch {
+	case strings.HasPrefix(p, "qSupported"):
+		s.send("PacketSize=4000;qXfer:features:read+;QStartNoAckMode+")
+	case p == "QStartNoAckMode":
+		s.send("OK")
+		s.noAck = true
+	case strings.HasPrefix(p, xferPrefix):
+		s.send(gdbXfer(gdbTargetXML, strings.TrimPrefix(p, xferPrefix)))
+	case p == "qAttached":
+		s.send("1")
+	case p == "qC":
+		s.send("QC1")
+	case p == "qfThreadInfo":
+		s.send("m1")
+	case p == "qsThreadInfo":
+		s.send("l")
+	default:
+		s.send("")
+	}
+}
+
+// gdbXfer returns the part of data requested by the arguments OFFSET,LENGTH
+// of a qXfer read.
+func gdbXfer(data, args string) string {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	offset, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	length, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	if offset >= uint64(len(data)) {
+		return "l"
+	}
+	if end := offset + length; end < uint64(len(data)) {
+		return "m" + data[offset:end]
+	}
+	return "l" + data[offset:]
+}
+
+// stopReply describes why the emulation stopped.
+func (s *gdbStub) stopReply(signal int) string {
+	if len(s.d.lastHits) > 0 {
+		hit := s.d.lastHits[0]
+		kind := "awatch"
+		switch hit.wp.kind {
+		case watchWrite:
+			kind = "watch"
+		case watchRead:
+			kind = "rwatch"
+		}
+		return fmt.Sprintf("T%02x%v:%04x;", signal, kind, hit.addr)
+	}
+	return fmt.Sprintf("S%02x", signal)
+}
+
+func (s *gdbStub) readRegisters() string {
+	reg := s.d.reg
+	var b strings.Builder
+	for _, val := range []uint16{reg.AF(), reg.BC(), reg.DE(), reg.HL(), reg.SP, reg.PC} {
+		fmt.Fprintf(&b, "%02x%02x", uint8(val), uint8(val>>8))
+	}
+	return b.String()
+}
+
+func (s *gdbStub) writeRegisters(args string) string {
+	data, err := hex.DecodeString(args)
+	if err != nil || len(data) < 2*gdbRegisterCount {
+		return "E01"
+	}
+	for n := 0; n < gdbRegisterCount; n += 1 {
+		s.setRegister(n, uint16(data[2*n])|uint16(data[2*n+1])<<8)
+	}
+	return "OK"
+}
+
+// writeRegister handles the arguments N=VALUE of a P packet.
+func (s *gdbStub) writeRegister(args string) string {
+	parts := strings.SplitN(args, "=", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	n, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil || n >= gdbRegisterCount {
+		return "E01"
+	}
+	data, err := hex.DecodeString(parts[1])
+	if err != nil || len(data) != 2 {
+		return "E01"
+	}
+	s.setRegister(int(n), uint16(data[0])|uint16(data[1])<<8)
+	return "OK"
+}
+
+func (s *gdbStub) setRegister(n int, val uint16) {
+	reg := s.d.reg
+	switch n {
+	case 0:
+		// The lower nibble of F is always 0.
+		reg.SetAF(val & 0xFFF0)
+	case 1:
+		reg.SetBC(val)
+	case 2:
+		reg.SetDE(val)
+	case 3:
+		reg.SetHL(val)
+	case 4:
+		reg.SP = val
+	case 5:
+		reg.PC = val
+	}
+}
+
+// parseGDBRange parses the arguments ADDR,LENGTH of memory packets.
+func parseGDBRange(s string) (uint16, int, error) {
+	parts := strings.SplitN(s, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	addr, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid address %q", parts[0])
+	}
+	length, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil || length > 0x10000 {
+		return 0, 0, fmt.Errorf("invalid length %q", parts[1])
+	}
+	return uint16(addr), int(length), nil
+}
+
+// readMemory reads memory like the CPU would see it, without triggering
+// watchpoints.
+func (s *gdbStub) readMemory(args string) string {
+	addr, length, err := parseGDBRange(args)
+	if err != nil {
+		return "E01"
+	}
+	var b strings.Builder
+	for i := 0; i < length; i += 1 {
+		fmt.Fprintf(&b, "%02x", s.d.mem.Read8(addr+uint16(i)))
+	}
+	if s.d.fault() != nil {
+		return "E02"
+	}
+	return b.String()
+}
+
+// writeMemory writes memory through the bus like the set mem command does,
+// so writes to the ROM area set the MBC registers.
+func (s *gdbStub) writeMemory(args string) string {
+	parts := strings.SplitN(args, ":", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	addr, length, err := parseGDBRange(parts[0])
+	if err != nil {
+		return "E01"
+	}
+	data, err := hex.DecodeString(parts[1])
+	if err != nil || len(data) != length {
+		return "E01"
+	}
+	for i, b := range data {
+		s.d.mem.Write8(addr+uint16(i), b)
+	}
+	if s.d.fault() != nil {
+		return "E02"
+	}
+	return "OK"
+}
+
+// breakpoint inserts or removes the breakpoint or watchpoint given by the
+// arguments TYPE,ADDR,KIND of a Z or z packet.
+func (s *gdbStub) breakpoint(insert bool, args string) string {
+	parts := strings.Split(args, ",")
+	if len(parts) < 3 {
+		return "E01"
+	}
+	addr, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+	// KIND is the length of the watched range for watchpoints.
+	length, err := strconv.ParseUint(parts[2], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+
+	var kind watchKind
+	switch parts[0] {
+	case "0", "1":
+		// There is no difference between software and hardware breakpoints.
+		id, ok := s.breaks[uint16(addr)]
+		if insert && !ok {
+			s.breaks[uint16(addr)] = s.d.addBreakpoint(location{bank: -1, addr: uint16(addr)}, "")
+		} else if !insert && ok {
+			s.d.deleteBreakpoint(id)
+			delete(s.breaks, uint16(addr))
+		}
+		return "OK"
+	case "2":
+		kind = watchWrite
+	case "3":
+		kind = watchRead
+	case "4":
+		kind = watchAccess
+	default:
+		return ""
+	}
+
+	end := addr + length - 1
+	if length == 0 || end > 0xFFFF {
+		return "E01"
+	}
+	w := gdbWatch{kind, uint16(addr), uint16(length)}
+	id, ok := s.watches[w]
+	if insert && !ok {
+		s.watches[w] = s.d.addWatchpoint(kind, location{bank: -1, addr: uint16(addr)}, uint16(end), false)
+	} else if !insert && ok {
+		s.d.deleteWatchpoint(id)
+		delete(s.watches, w)
+	}
+	return "OK"
+}
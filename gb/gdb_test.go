@@ -0,0 +1,146 @@
+package gb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gdbClient speaks the client side of the GDB Remote Serial Protocol.
+type gdbClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *gdbClient) send(packet string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintf(c.conn, "$%v#%02x", packet, gdbChecksum(packet)); err != nil {
+		c.t.Fatalf("Could not send %q: %v", packet, err)
+	}
+}
+
+// reply reads the next packet and acknowledges it.
+func (c *gdbClient) reply() string {
+	c.t.Helper()
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			c.t.Fatalf("Could not read reply: %v", err)
+		}
+		if b == '$' {
+			break
+		}
+	}
+	data, err := c.r.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("Could not read reply: %v", err)
+	}
+	data = data[:len(data)-1]
+	var sum [2]byte
+	if _, err := io.ReadFull(c.r, sum[:]); err != nil {
+		c.t.Fatalf("Could not read checksum: %v", err)
+	}
+	if checksum, err := strconv.ParseUint(string(sum[:]), 16, 8); err != nil || uint8(checksum) != gdbChecksum(data) {
+		c.t.Fatalf("Invalid checksum %q of reply %q", sum, data)
+	}
+	c.conn.Write([]byte("+"))
+	return data
+}
+
+// expect sends a packet and checks the reply.
+func (c *gdbClient) expect(packet, want string) {
+	c.t.Helper()
+	c.send(packet)
+	if got := c.reply(); got != want {
+		c.t.Fatalf("%q: got %q, want %q", packet, got, want)
+	}
+}
+
+func TestGDBStub(t *testing.T) {
+	emu, err := NewEmulator(nil, callTestROM())
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	defer l.Close()
+	emu.ServeGDB(l)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := emu.StepInstruction(); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &gdbClient{t, conn, bufio.NewReader(conn)}
+
+	c.send("qSupported:swbreak+;hwbreak+")
+	if reply := c.reply(); !strings.Contains(reply, "qXfer:features:read+") {
+		t.Fatalf("qSupported: got %q, want target description support", reply)
+	}
+	c.send("qXfer:features:read:target.xml:0,fff")
+	if reply := c.reply(); !strings.HasPrefix(reply, "l") || !strings.Contains(reply, `<reg name="pc"`) {
+		t.Fatalf("Invalid target description %q", reply)
+	}
+	c.expect("?", "S05")
+
+	// Registers after the boot ROM: AF=01B0 BC=0013 DE=00D8 HL=014D SP=FFFE PC=0100
+	c.expect("g", "b001"+"1300"+"d800"+"4d01"+"feff"+"0001")
+	c.expect("P1=3412", "OK")
+	c.expect("p1", "3412")
+	c.expect("P0=ff12", "OK")
+	c.expect("p0", "f012")
+	c.expect("p6", "E01")
+
+	c.expect("m100,5", "cd500118fb")
+	c.expect("Mc000,2:abcd", "OK")
+	c.expect("mc000,2", "abcd")
+
+	c.expect("Z0,160,1", "OK")
+	c.expect("c", "S05")
+	c.expect("p5", "6001")
+	c.expect("z0,160,1", "OK")
+	c.expect("s", "S05")
+	c.expect("p5", "6101")
+
+	// The next CALL 0x0160 pushes its return address to 0xFFFA.
+	c.expect("Z2,fffa,2", "OK")
+	c.send("c")
+	if reply := c.reply(); !strings.HasPrefix(reply, "T05watch:fff") {
+		t.Fatalf("Got %q, want a write watchpoint hit", reply)
+	}
+	c.expect("p5", "6001")
+	c.expect("mfffa,2", "5501")
+	c.expect("z2,fffa,2", "OK")
+
+	c.send("c")
+	conn.Write([]byte{0x03})
+	if reply := c.reply(); reply != "S02" {
+		t.Fatalf("Got %q after interrupt, want S02", reply)
+	}
+	c.expect("?", "S02")
+	c.expect("D", "OK")
+}
@@ -0,0 +1,149 @@
+package gb
+
+const ioStart = 0xFF00
+const ioEnd = ioStart + ioMemSize
+
+// ioRegister describes a register in the I/O area 0xFF00-0xFF7F and the
+// component owning it.
+type ioRegister struct {
+	name  string
+	owner string
+	// readMask has a 1 for every unused bit. Unused bits always read as 1.
+	readMask uint8
+	// writeMask has a 1 for every bit the CPU can write. The other bits
+	// are read-only and keep their value on writes.
+	writeMask uint8
+	// read returns the value of a register implemented by its owner.
+	// If nil, the stored value is returned.
+	read func() uint8
+	// write is called with the new value (after applying writeMask) of a register
+	// implemented by its owner. If nil, the new value is stored.
+	write func(val uint8)
+	value uint8
+}
+
+// ioBus dispatches accesses to the I/O area to the owners of the registers.
+type ioBus struct {
+	regs [ioMemSize]*ioRegister
+	log  Logger
+}
+
+func newIOBus() *ioBus {
+	return &ioBus{
+		log: nopLogger{},
+	}
+}
+
+// mapRegister makes reg the register at addr.
+func (b *ioBus) mapRegister(addr uint16, reg *ioRegister) {
+	b.regs[addr-ioStart] = reg
+}
+
+// register returns the register at addr or nil if there is none.
+func (b *ioBus) register(addr uint16) *ioRegister {
+	return b.regs[addr-ioStart]
+}
+
+// read reads the register at addr. Unmapped registers read as 0xFF.
+func (b *ioBus) read(addr uint16) uint8 {
+	reg := b.regs[addr-ioStart]
+	if reg == nil {
+		if b.log.Enabled(LogIO, LogDebug) {
+			b.log.Logf(LogIO, LogDebug, "Reading from unmapped I/O register 0x%04X", addr)
+		}
+		return 0xFF
+	}
+
+	value := reg.value
+	if reg.read != nil {
+		value = reg.read()
+	}
+	value |= reg.readMask
+	if b.log.Enabled(LogIO, LogDebug) {
+		b.log.Logf(LogIO, LogDebug, "Reading %v (%v) at 0x%04X: 0x%02X",
+			reg.name, reg.owner, addr, value)
+	}
+	return value
+}
+
+// write writes the register at addr. Writes to unmapped registers are ignored.
+func (b *ioBus) write(addr uint16, val uint8) {
+	reg := b.regs[addr-ioStart]
+	if reg == nil {
+		if b.log.Enabled(LogIO, LogDebug) {
+			b.log.Logf(LogIO, LogDebug, "Writing to unmapped I/O register 0x%04X: 0x%02X", addr, val)
+		}
+		return
+	}
+	if b.log.Enabled(LogIO, LogDebug) {
+		b.log.Logf(LogIO, LogDebug, "Writing %v (%v) at 0x%04X: 0x%02X",
+			reg.name, reg.owner, addr, val)
+	}
+
+	current := reg.value
+	if reg.read != nil {
+		current = reg.read()
+	}
+	val = (current & ^reg.writeMask) | (val & reg.writeMask)
+	if reg.write != nil {
+		reg.write(val)
+	} else {
+		reg.value = val
+	}
+}
+
+// plainRegister describes a register that only stores its value because its
+// owner is not emulated yet.
+type plainRegister struct {
+	addr      uint16
+	name      string
+	owner     string
+	readMask  uint8
+	writeMask uint8
+	// postBoot is the value the boot ROM leaves in the register.
+	postBoot uint8
+}
+
+var plainRegisters = []plainRegister{
+	{0xFF04, "DIV", "timer", 0x00, 0xFF, 0xAB},
+	{0xFF05, "TIMA", "timer", 0x00, 0xFF, 0x00},
+	{0xFF06, "TMA", "timer", 0x00, 0xFF, 0x00},
+	{0xFF07, "TAC", "timer", 0xF8, 0x07, 0x00},
+	{0xFF0F, "IF", "interrupts", 0xE0, 0x1F, 0x01},
+	{0xFF10, "NR10", "apu", 0x80, 0x7F, 0x00},
+	{0xFF11, "NR11", "apu", 0x3F, 0xFF, 0x80},
+	{0xFF12, "NR12", "apu", 0x00, 0xFF, 0xF3},
+	{0xFF13, "NR13", "apu", 0xFF, 0xFF, 0x00},
+	{0xFF14, "NR14", "apu", 0xBF, 0xC7, 0x00},
+	{0xFF16, "NR21", "apu", 0x3F, 0xFF, 0x00},
+	{0xFF17, "NR22", "apu", 0x00, 0xFF, 0x00},
+	{0xFF18, "NR23", "apu", 0xFF, 0xFF, 0x00},
+	{0xFF19, "NR24", "apu", 0xBF, 0xC7, 0x00},
+	{0xFF1A, "NR30", "apu", 0x7F, 0x80, 0x00},
+	{0xFF1B, "NR31", "apu", 0xFF, 0xFF, 0x00},
+	{0xFF1C, "NR32", "apu", 0x9F, 0x60, 0x00},
+	{0xFF1D, "NR33", "apu", 0xFF, 0xFF, 0x00},
+	{0xFF1E, "NR34", "apu", 0xBF, 0xC7, 0x00},
+	{0xFF20, "NR41", "apu", 0xFF, 0x3F, 0x00},
+	{0xFF21, "NR42", "apu", 0x00, 0xFF, 0x00},
+	{0xFF22, "NR43", "apu", 0x00, 0xFF, 0x00},
+	{0xFF23, "NR44", "apu", 0xBF, 0xC0, 0x00},
+	{0xFF24, "NR50", "apu", 0x00, 0xFF, 0x77},
+	{0xFF25, "NR51", "apu", 0x00, 0xFF, 0xF3},
+	{0xFF26, "NR52", "apu", 0x70, 0x80, 0x81},
+	{0xFF40, "LCDC", "ppu", 0x00, 0xFF, 0x91},
+	{0xFF41, "STAT", "ppu", 0x80, 0x78, 0x05},
+	{0xFF42, "SCY", "ppu", 0x00, 0xFF, 0x00},
+	{0xFF43, "SCX", "ppu", 0x00, 0xFF, 0x00},
+	{0xFF44, "LY", "ppu", 0x00, 0x00, 0x00},
+	{0xFF45, "LYC", "ppu", 0x00, 0xFF, 0x00},
+	{0xFF47, "BGP", "ppu", 0x00, 0xFF, 0xFC},
+	{0xFF48, "OBP0", "ppu", 0x00, 0xFF, 0x00},
+	{0xFF49, "OBP1", "ppu", 0x00, 0xFF, 0x00},
+	{0xFF4A, "WY", "ppu", 0x00, 0xFF, 0x00},
+	{0xFF4B, "WX", "ppu", 0x00, 0xFF, 0x00},
+}
+
+// waveRAMStart is the start of the 16 byte wave pattern RAM of the APU.
+const waveRAMStart = 0xFF30
+const waveRAMSize = 0x10
@@ -0,0 +1,54 @@
+package gb
+
+// Buttons is a bit set of the Game Boy buttons that are currently pressed.
+type Buttons uint8
+
+const (
+	ButtonRight Buttons = 1 << iota
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+	ButtonA
+	ButtonB
+	ButtonSelect
+	ButtonStart
+)
+
+const joypadSelectDirections uint8 = 1 << 4
+const joypadSelectButtons uint8 = 1 << 5
+
+// Joypad implements the P1 register at 0xFF00.
+type Joypad struct {
+	pressed Buttons
+	// selected holds bits 4 and 5 of P1 as last written by the CPU.
+	selected uint8
+}
+
+func NewJoypad() *Joypad {
+	return &Joypad{
+		selected: joypadSelectDirections | joypadSelectButtons,
+	}
+}
+
+// SetPressed sets the buttons that are currently held down.
+func (j *Joypad) SetPressed(buttons Buttons) {
+	j.pressed = buttons
+}
+
+// read returns the value of P1. A pressed button reads as 0 in the lower
+// nibble if its group is selected (by a 0 in bit 4 or 5).
+func (j *Joypad) read() uint8 {
+	lines := uint8(0x0F)
+	if j.selected&joypadSelectDirections == 0 {
+		lines &= ^uint8(j.pressed & 0x0F)
+	}
+	if j.selected&joypadSelectButtons == 0 {
+		lines &= ^uint8(j.pressed >> 4)
+	}
+	return 0xC0 | j.selected | lines
+}
+
+// write sets the group select bits of P1.
+func (j *Joypad) write(val uint8) {
+	j.selected = val & (joypadSelectDirections | joypadSelectButtons)
+}
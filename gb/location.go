@@ -0,0 +1,86 @@
+package gb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// location is an address given to the debugger, optionally with the bank it
+// has to be in, e.g. 02:4A00 for address 0x4A00 in ROM bank 2.
+type location struct {
+	// bank is -1 if the location matches any bank.
+	bank int
+	addr uint16
+}
+
+// parseLocation parses a hexadecimal address with optional bank prefix.
+// Both may start with 0x or $.
+func parseLocation(s string) (location, error) {
+	loc := location{bank: -1}
+	addr := s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		bank, err := strconv.ParseUint(trimHexPrefix(s[:i]), 16, 16)
+		if err != nil {
+			return loc, fmt.Errorf("invalid bank %q", s[:i])
+		}
+		loc.bank = int(bank)
+		addr = s[i+1:]
+	}
+	a, err := strconv.ParseUint(trimHexPrefix(addr), 16, 16)
+	if err != nil {
+		return loc, fmt.Errorf("invalid address %q", addr)
+	}
+	loc.addr = uint16(a)
+	return loc, nil
+}
+
+// parseLocation parses a location given as symbol name or address.
+// Symbols have the bank they are defined in.
+func (d *Debugger) parseLocation(s string) (location, error) {
+	if sym, ok := d.symbols.Lookup(s); ok {
+		return location{bank: sym.Bank, addr: sym.Addr}, nil
+	}
+	return parseLocation(s)
+}
+
+func trimHexPrefix(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return s[2:]
+	}
+	return strings.TrimPrefix(s, "$")
+}
+
+func (l location) String() string {
+	if l.bank < 0 {
+		return fmt.Sprintf("0x%04X", l.addr)
+	}
+	return fmt.Sprintf("%02X:%04X", l.bank, l.addr)
+}
+
+// busBank returns the bank mapped at addr if the bus is banked, else 0.
+func busBank(bus Bus, addr uint16) int {
+	if b, ok := bus.(Banker); ok {
+		return b.Bank(addr)
+	}
+	return 0
+}
+
+// matches reports whether addr in the given bank is the location.
+func (l location) matches(bank int, addr uint16) bool {
+	return l.addr == addr && (l.bank < 0 || l.bank == bank)
+}
+
+// describe returns addr in the current bank with the nearest symbol, if any.
+func (d *Debugger) describe(addr uint16) string {
+	return d.symbols.describe(busBank(d.mem, addr), addr)
+}
+
+// read reads the byte offset bytes after the location. Locations with bank
+// are read from that bank even if it is not mapped.
+func (d *Debugger) read(l location, offset uint16) uint8 {
+	if b, ok := d.mem.(Banker); ok && l.bank >= 0 {
+		return b.ReadBank(l.bank, l.addr+offset)
+	}
+	return d.mem.Read8(l.addr + offset)
+}
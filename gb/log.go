@@ -0,0 +1,143 @@
+package gb
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LogLevel is the severity of a log message. Higher levels are more verbose.
+type LogLevel int
+
+const (
+	LogOff LogLevel = iota
+	LogError
+	LogWarn
+	LogInfo
+	LogDebug
+	LogTrace
+)
+
+var logLevelNames = [...]string{"off", "error", "warn", "info", "debug", "trace"}
+
+func (l LogLevel) String() string {
+	if l < 0 || int(l) >= len(logLevelNames) {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// LogCategory is the subsystem a log message originates from.
+type LogCategory int
+
+const (
+	LogCPU LogCategory = iota
+	LogMem
+	LogIO
+	LogPPU
+	LogAPU
+	LogCart
+	numLogCategories
+)
+
+var logCategoryNames = [numLogCategories]string{"cpu", "mem", "io", "ppu", "apu", "cart"}
+
+func (c LogCategory) String() string {
+	if c < 0 || c >= numLogCategories {
+		return fmt.Sprintf("LogCategory(%d)", int(c))
+	}
+	return logCategoryNames[c]
+}
+
+// Logger receives the diagnostic messages of the emulator.
+type Logger interface {
+	// Enabled reports whether messages of the given category and level are logged.
+	// Callers check it before building expensive messages.
+	Enabled(cat LogCategory, level LogLevel) bool
+	Logf(cat LogCategory, level LogLevel, format string, args ...interface{})
+}
+
+// nopLogger discards all messages. It is the default logger.
+type nopLogger struct{}
+
+func (nopLogger) Enabled(LogCategory, LogLevel) bool                 { return false }
+func (nopLogger) Logf(LogCategory, LogLevel, string, ...interface{}) {}
+
+// LevelLogger writes messages to a writer if their level does not exceed
+// the level configured for their category.
+type LevelLogger struct {
+	out    io.Writer
+	levels [numLogCategories]LogLevel
+}
+
+// NewLevelLogger creates a logger writing to out with all categories turned off.
+func NewLevelLogger(out io.Writer) *LevelLogger {
+	return &LevelLogger{out: out}
+}
+
+// SetLevel sets the most verbose level that is logged for a category.
+func (l *LevelLogger) SetLevel(cat LogCategory, level LogLevel) {
+	l.levels[cat] = level
+}
+
+// Configure sets the levels from a comma separated list of category=level
+// pairs, e.g. "io=debug,cart=info". The category "all" or a level without
+// category applies to all categories.
+func (l *LevelLogger) Configure(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		catName, levelName := "all", item
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			catName, levelName = item[:i], item[i+1:]
+		}
+
+		level, ok := parseLogLevel(levelName)
+		if !ok {
+			return fmt.Errorf("unknown log level %q", levelName)
+		}
+		if catName == "all" {
+			for cat := range l.levels {
+				l.levels[cat] = level
+			}
+			continue
+		}
+		cat, ok := parseLogCategory(catName)
+		if !ok {
+			return fmt.Errorf("unknown log category %q", catName)
+		}
+		l.levels[cat] = level
+	}
+	return nil
+}
+
+func (l *LevelLogger) Enabled(cat LogCategory, level LogLevel) bool {
+	return level != LogOff && level <= l.levels[cat]
+}
+
+func (l *LevelLogger) Logf(cat LogCategory, level LogLevel, format string, args ...interface{}) {
+	if !l.Enabled(cat, level) {
+		return
+	}
+	fmt.Fprintf(l.out, "[%-4v %-5v] %v\n", cat, level, fmt.Sprintf(format, args...))
+}
+
+func parseLogLevel(name string) (LogLevel, bool) {
+	for level, n := range logLevelNames {
+		if n == name {
+			return LogLevel(level), true
+		}
+	}
+	return LogOff, false
+}
+
+func parseLogCategory(name string) (LogCategory, bool) {
+	for cat, n := range logCategoryNames {
+		if n == name {
+			return LogCategory(cat), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,270 @@
+package gb
+
+const BootROMSize = 256
+const vramSize = 0x2000
+const wramSize = 0x2000
+const oamSize = 0xA0
+const hramSize = 0x7F
+const ioMemSize = 0x80
+
+const bootROMDisableAddr = 0xFF50
+const interruptFlagAddr = 0xFF0F
+
+type Memory struct {
+	bootROM       [BootROMSize]byte
+	bootROMMapped bool
+	cart          *Cartridge
+	joypad        *Joypad
+	serial        *Serial
+	vram          [vramSize]byte
+	wram          [wramSize]byte
+	oam           [oamSize]byte
+	hram          [hramSize]byte
+	io            *ioBus
+	ie            uint8
+	log           Logger
+	// fault is the first error that occurred since the last call to Fault.
+	fault error
+}
+
+// NewMemory creates the memory map for the given cartridge. If bootROM is nil
+// the boot ROM is not mapped and the cartridge is visible from address 0.
+func NewMemory(bootROM *[BootROMSize]byte, cart *Cartridge, joypad *Joypad, serial *Serial) *Memory {
+	m := &Memory{
+		cart:   cart,
+		joypad: joypad,
+		serial: serial,
+		io:     newIOBus(),
+		log:    nopLogger{},
+	}
+	if bootROM != nil {
+		m.bootROM = *bootROM
+		m.bootROMMapped = true
+	}
+	m.mapIO(bootROM == nil)
+	return m
+}
+
+// mapIO maps the registers of all components to the I/O area. If postBoot
+// is set the registers get the values the boot ROM leaves behind.
+func (m *Memory) mapIO(postBoot bool) {
+	m.io.mapRegister(0xFF00, &ioRegister{
+		name:      "P1",
+		owner:     "joypad",
+		readMask:  0xC0,
+		writeMask: 0x30,
+		read:      m.joypad.read,
+		write:     m.joypad.write,
+	})
+
+	m.io.mapRegister(0xFF01, &ioRegister{
+		name:      "SB",
+		owner:     "serial",
+		writeMask: 0xFF,
+		read:      m.serial.readSB,
+		write:     m.serial.writeSB,
+	})
+	m.io.mapRegister(0xFF02, &ioRegister{
+		name:      "SC",
+		owner:     "serial",
+		readMask:  0x7E,
+		writeMask: 0x81,
+		read:      m.serial.readSC,
+		write:     m.serial.writeSC,
+	})
+	m.serial.requestInterrupt = m.requestInterrupt
+
+	for _, r := range plainRegisters {
+		reg := &ioRegister{
+			name:      r.name,
+			owner:     r.owner,
+			readMask:  r.readMask,
+			writeMask: r.writeMask,
+		}
+		if postBoot {
+			reg.value = r.postBoot
+		}
+		m.io.mapRegister(r.addr, reg)
+	}
+
+	for addr := uint16(waveRAMStart); addr < waveRAMStart+waveRAMSize; addr += 1 {
+		m.io.mapRegister(addr, &ioRegister{
+			name:      "WAVE",
+			owner:     "apu",
+			writeMask: 0xFF,
+		})
+	}
+
+	dma := &ioRegister{
+		name:      "DMA",
+		owner:     "ppu",
+		writeMask: 0xFF,
+		value:     0xFF,
+	}
+	dma.write = func(val uint8) {
+		dma.value = val
+		m.oamDMA(val)
+	}
+	m.io.mapRegister(0xFF46, dma)
+
+	m.io.mapRegister(bootROMDisableAddr, &ioRegister{
+		name:      "BOOT",
+		owner:     "mem",
+		readMask:  0xFF,
+		writeMask: 0xFF,
+		write: func(val uint8) {
+			if val != 0 && m.bootROMMapped {
+				m.log.Logf(LogMem, LogInfo, "Boot ROM unmapped")
+				m.bootROMMapped = false
+			}
+		},
+	})
+}
+
+// requestInterrupt sets the given flag in the interrupt flag register IF.
+func (m *Memory) requestInterrupt(flag uint8) {
+	reg := m.io.register(interruptFlagAddr)
+	reg.value |= flag
+}
+
+// oamDMA copies 160 bytes from page src to OAM. The transfer is done at once
+// instead of taking 160 machine cycles.
+func (m *Memory) oamDMA(src uint8) {
+	if src >= 0xE0 {
+		// The DMA controller sees WRAM at 0xE000-0xFFFF.
+		src -= 0x20
+	}
+	base := uint16(src) << 8
+	for i := uint16(0); i < oamSize; i += 1 {
+		m.oam[i] = m.Read8(base + i)
+	}
+}
+
+func (m *Memory) Read8(addr uint16) uint8 {
+	if addr < 0x0100 && m.bootROMMapped {
+		return m.bootROM[addr]
+	} else if addr < 0x8000 {
+		return m.cart.readROM(addr)
+	} else if addr >= 0x8000 && addr < 0xA000 {
+		return m.vram[addr-0x8000]
+	} else if addr >= 0xA000 && addr < 0xC000 {
+		return m.cart.readRAM(addr)
+	} else if addr >= 0xC000 && addr < 0xFE00 {
+		// 0xE000-0xFDFF mirrors 0xC000-0xDDFF (echo RAM)
+		return m.wram[(addr-0xC000)%wramSize]
+	} else if addr >= 0xFE00 && addr < 0xFEA0 {
+		return m.oam[addr-0xFE00]
+	} else if addr >= 0xFEA0 && addr < ioStart {
+		// Unusable area, the DMG reads 0x00 while OAM is accessible.
+		return 0x00
+	} else if addr >= ioStart && addr < ioEnd {
+		return m.io.read(addr)
+	} else if addr >= 0xFF80 && addr < 0xFFFF {
+		return m.hram[addr-0xFF80]
+	} else if addr == 0xFFFF {
+		return m.ie
+	}
+	m.setFault(ErrUnmappedAddress{Addr: addr})
+	return 0xFF
+}
+
+func (m *Memory) Read16(addr uint16) uint16 {
+	// Little endian
+	loByte := uint16(m.Read8(addr))
+	hiByte := uint16(m.Read8(addr + 1))
+	return (hiByte << 8) | loByte
+}
+
+func (m *Memory) Write8(addr uint16, val uint8) {
+	if addr < 0x8000 {
+		m.cart.writeROM(addr, val)
+	} else if addr >= 0x8000 && addr < 0xA000 {
+		m.vram[addr-0x8000] = val
+	} else if addr >= 0xA000 && addr < 0xC000 {
+		m.cart.writeRAM(addr, val)
+	} else if addr >= 0xC000 && addr < 0xFE00 {
+		m.wram[(addr-0xC000)%wramSize] = val
+	} else if addr >= 0xFE00 && addr < 0xFEA0 {
+		m.oam[addr-0xFE00] = val
+	} else if addr >= 0xFEA0 && addr < ioStart {
+		// Writes to the unusable area are ignored.
+	} else if addr >= ioStart && addr < ioEnd {
+		m.io.write(addr, val)
+	} else if addr >= 0xFF80 && addr < 0xFFFF {
+		m.hram[addr-0xFF80] = val
+	} else if addr == 0xFFFF {
+		m.ie = val
+	} else {
+		m.setFault(ErrUnmappedAddress{Addr: addr, Write: true})
+	}
+}
+
+func (m *Memory) Write16(addr uint16, val uint16) {
+	// Little endian
+	loByte := uint8(val)
+	hiByte := uint8(val >> 8)
+	m.Write8(addr, loByte)
+	m.Write8(addr+1, hiByte)
+}
+
+// Bank returns the number of the cartridge ROM or RAM bank mapped at addr.
+// All other memory has a single bank 0.
+func (m *Memory) Bank(addr uint16) int {
+	if addr < 0x0100 && m.bootROMMapped {
+		return 0
+	} else if addr < 0x4000 {
+		return m.cart.romBank0()
+	} else if addr < 0x8000 {
+		return m.cart.ROMBank()
+	} else if addr >= 0xA000 && addr < 0xC000 {
+		return m.cart.ramBank()
+	}
+	return 0
+}
+
+// ReadBank reads addr from the given cartridge ROM or RAM bank without
+// switching banks. Other addresses are read as usual.
+func (m *Memory) ReadBank(bank int, addr uint16) uint8 {
+	if addr < 0x8000 && !(addr < 0x0100 && m.bootROMMapped) {
+		return m.cart.readROMBank(bank, addr)
+	} else if addr >= 0xA000 && addr < 0xC000 {
+		return m.cart.readRAMBank(bank, addr)
+	}
+	return m.Read8(addr)
+}
+
+// WriteBank patches the given cartridge ROM or RAM bank at addr. Other
+// addresses are written as usual.
+func (m *Memory) WriteBank(bank int, addr uint16, val uint8) bool {
+	if addr < 0x8000 && !(addr < 0x0100 && m.bootROMMapped) {
+		return m.cart.writeROMBank(bank, addr, val)
+	} else if addr >= 0xA000 && addr < 0xC000 {
+		return m.cart.writeRAMBank(bank, addr, val)
+	} else if addr < 0x0100 {
+		m.bootROM[addr] = val
+		return true
+	}
+	m.Write8(addr, val)
+	return m.Fault() == nil
+}
+
+// Tick advances the memory mapped devices by the given number of clock cycles.
+// None of them is clocked yet.
+func (m *Memory) Tick(cycles uint) {
+}
+
+// Fault returns the first error caused by a memory access since the last
+// call to Fault and clears it. Invalid reads return 0xFF and invalid writes
+// are ignored, so the caller decides whether an error stops the emulation.
+func (m *Memory) Fault() error {
+	err := m.fault
+	m.fault = nil
+	return err
+}
+
+func (m *Memory) setFault(err error) {
+	m.log.Logf(LogMem, LogWarn, "%v", err)
+	if m.fault == nil {
+		m.fault = err
+	}
+}
@@ -0,0 +1,21 @@
+package gb
+
+import "testing"
+
+func TestUnusableArea(t *testing.T) {
+	cart, err := NewCartridge(callTestROM())
+	if err != nil {
+		t.Fatalf("Could not create cartridge: %v", err)
+	}
+	mem := NewMemory(nil, cart, NewJoypad(), NewSerial())
+
+	for _, addr := range []uint16{0xFEA0, 0xFEFF} {
+		mem.Write8(addr, 0x12)
+		if got := mem.Read8(addr); got != 0x00 {
+			t.Errorf("0x%04X: got 0x%02X, want 0x00", addr, got)
+		}
+		if err := mem.Fault(); err != nil {
+			t.Errorf("0x%04X: unexpected fault: %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package gb
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mooneyeDir contains the mooneye test ROMs in any directory structure,
+// e.g. testdata/mooneye/acceptance/bits/mem_oam.gb.
+const mooneyeDir = "testdata/mooneye"
+
+// mooneyeMaxFrames is the time (in frames) a ROM gets to reach the debug trap.
+const mooneyeMaxFrames = 60 * 30
+
+var mooneyeResults = flag.String("mooneye.results", filepath.Join(mooneyeDir, "results.tsv"),
+	"File to write the pass/fail matrix of the mooneye test ROMs to (empty: none).")
+
+// mooneyeResult is a row of the pass/fail matrix.
+type mooneyeResult struct {
+	name   string
+	result string
+}
+
+// TestMooneye runs the mooneye test ROMs. They signal the end of the test with
+// LD B,B and pass if the registers hold the Fibonacci numbers 3, 5, 8, 13, 21, 34.
+func TestMooneye(t *testing.T) {
+	var roms []string
+	filepath.Walk(mooneyeDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".gb") {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	if len(roms) == 0 {
+		t.Skipf("No ROMs found in %v", mooneyeDir)
+	}
+
+	var results []mooneyeResult
+	for _, path := range roms {
+		path := path
+		name := strings.TrimSuffix(filepath.ToSlash(strings.TrimPrefix(path, mooneyeDir+string(filepath.Separator))), ".gb")
+		result := "skip"
+		t.Run(name, func(t *testing.T) {
+			result = runMooneyeROM(t, path)
+		})
+		results = append(results, mooneyeResult{name, result})
+	}
+
+	if *mooneyeResults != "" {
+		if err := writeMooneyeResults(*mooneyeResults, results); err != nil {
+			t.Errorf("Could not write results: %v", err)
+		}
+	}
+}
+
+// runMooneyeROM runs a single ROM and returns "pass", "fail" or "error".
+// ROMs with unsupported cartridge types are skipped.
+func runMooneyeROM(t *testing.T, path string) string {
+	rom, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("Could not read ROM: %v", err)
+		return "error"
+	}
+	emu, err := NewEmulator(nil, rom)
+	if err != nil {
+		t.Skipf("Could not create emulator: %v", err)
+	}
+	emu.SetIllegalOpcodePolicy(IllegalOpcodeError)
+	emu.EnableDebugTrap(true)
+
+	for emu.Frames() < mooneyeMaxFrames {
+		err := emu.StepInstruction()
+		var trap ErrDebugTrap
+		if errors.As(err, &trap) {
+			break
+		}
+		if err != nil {
+			t.Errorf("Emulation failed: %v", err)
+			return "error"
+		}
+	}
+	if emu.Frames() >= mooneyeMaxFrames {
+		t.Errorf("No result after %v frames", mooneyeMaxFrames)
+		return "error"
+	}
+
+	reg := emu.Registers()
+	if reg.B == 3 && reg.C == 5 && reg.D == 8 && reg.E == 13 && reg.H == 21 && reg.L == 34 {
+		return "pass"
+	}
+	t.Errorf("Failed: B=%v C=%v D=%v E=%v H=%v L=%v", reg.B, reg.C, reg.D, reg.E, reg.H, reg.L)
+	return "fail"
+}
+
+func writeMooneyeResults(path string, results []mooneyeResult) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "rom\tresult\n")
+	for _, r := range results {
+		fmt.Fprintf(&b, "%v\t%v\n", r.name, r.result)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,214 @@
+package gb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A movie file starts with the magic, the format version and the header
+// followed by one byte per frame with the buttons held during that frame.
+const movieMagic = "GBMV"
+const movieVersion = 1
+
+// ModelDMG is the only model emulated so far.
+const ModelDMG = "DMG"
+
+// MovieHeader describes the machine a movie was recorded on. Playback only
+// gives the same result on the same machine.
+type MovieHeader struct {
+	// ROMChecksum is the CRC-32 of the cartridge ROM.
+	ROMChecksum uint32
+	Model       string
+	// BootROM is set if the movie starts with the boot ROM instead of skipping it.
+	BootROM         bool
+	BootROMChecksum uint32
+	// RTCStart is the time (Unix seconds) of the cartridge real time clock
+	// at the start of the movie.
+	RTCStart int64
+	// StartState is the save state the movie starts from. If it is empty
+	// the movie starts at power on.
+	StartState []byte
+}
+
+// Movie is a recording of the input of every frame.
+type Movie struct {
+	Header MovieHeader
+	Inputs []Buttons
+}
+
+// ReadMovie reads a movie written by Movie.Write.
+func ReadMovie(r io.Reader) (*Movie, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(movieMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != movieMagic {
+		return nil, errors.New("not a movie file")
+	}
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version > movieVersion {
+		return nil, fmt.Errorf("movie file has unknown version %v", version)
+	}
+
+	m := &Movie{}
+	h := &m.Header
+	var bootROM uint8
+	fields := []interface{}{&h.ROMChecksum, &bootROM, &h.BootROMChecksum, &h.RTCStart}
+	for _, field := range fields {
+		if err := binary.Read(br, binary.LittleEndian, field); err != nil {
+			return nil, fmt.Errorf("invalid movie header (%v)", err)
+		}
+	}
+	h.BootROM = bootROM != 0
+	model, err := readMovieBytes(br)
+	if err != nil {
+		return nil, err
+	}
+	h.Model = string(model)
+	if h.StartState, err = readMovieBytes(br); err != nil {
+		return nil, err
+	}
+	if len(h.StartState) == 0 {
+		h.StartState = nil
+	}
+	var frames uint32
+	if err := binary.Read(br, binary.LittleEndian, &frames); err != nil {
+		return nil, fmt.Errorf("invalid movie header (%v)", err)
+	}
+
+	inputs := make([]byte, frames)
+	if _, err := io.ReadFull(br, inputs); err != nil {
+		return nil, fmt.Errorf("movie file is truncated (%v)", err)
+	}
+	m.Inputs = make([]Buttons, frames)
+	for i, b := range inputs {
+		m.Inputs[i] = Buttons(b)
+	}
+	return m, nil
+}
+
+func readMovieBytes(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("invalid movie header (%v)", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("invalid movie header (%v)", err)
+	}
+	return data, nil
+}
+
+// Write writes the movie to w.
+func (m *Movie) Write(w io.Writer) error {
+	h := &m.Header
+	bw := bufio.NewWriter(w)
+	bw.WriteString(movieMagic)
+	var bootROM uint8
+	if h.BootROM {
+		bootROM = 1
+	}
+	fields := []interface{}{
+		uint16(movieVersion), h.ROMChecksum, bootROM, h.BootROMChecksum, h.RTCStart,
+		uint32(len(h.Model)), []byte(h.Model),
+		uint32(len(h.StartState)), h.StartState,
+		uint32(len(m.Inputs)),
+	}
+	for _, field := range fields {
+		binary.Write(bw, binary.LittleEndian, field)
+	}
+	for _, b := range m.Inputs {
+		bw.WriteByte(uint8(b))
+	}
+	return bw.Flush()
+}
+
+// movieHeader returns the header for a movie starting at the current state.
+func (e *Emulator) movieHeader() MovieHeader {
+	return MovieHeader{
+		ROMChecksum:     e.cart.romChecksum,
+		Model:           ModelDMG,
+		BootROM:         e.withBootROM,
+		BootROMChecksum: e.bootROMChecksum,
+	}
+}
+
+// RecordMovie starts recording the input of every frame into a new movie.
+// Unless the emulation is at power on, the movie starts with a save state.
+func (e *Emulator) RecordMovie(rtcStart int64) (*Movie, error) {
+	m := &Movie{Header: e.movieHeader()}
+	m.Header.RTCStart = rtcStart
+	if e.cycles != 0 {
+		var state bytes.Buffer
+		if err := e.SaveState(&state); err != nil {
+			return nil, err
+		}
+		m.Header.StartState = state.Bytes()
+	}
+	e.movie = m
+	e.moviePlayback = false
+	e.movieStart = e.frames
+	return m, nil
+}
+
+// PlayMovie starts the playback of a movie. The input of the movie replaces
+// the one set with SetInput until the movie ends.
+func (e *Emulator) PlayMovie(m *Movie) error {
+	want := e.movieHeader()
+	switch {
+	case m.Header.ROMChecksum != want.ROMChecksum:
+		return errors.New("movie was recorded with a different cartridge ROM")
+	case m.Header.Model != want.Model:
+		return fmt.Errorf("movie was recorded on model %v", m.Header.Model)
+	case m.Header.BootROM != want.BootROM || m.Header.BootROMChecksum != want.BootROMChecksum:
+		return errors.New("movie was recorded with a different boot ROM setting")
+	}
+	if len(m.Header.StartState) > 0 {
+		if err := e.LoadState(bytes.NewReader(m.Header.StartState)); err != nil {
+			return err
+		}
+	} else if e.cycles != 0 {
+		return errors.New("movie starts at power on but the emulation is running")
+	}
+
+	e.movie = m
+	e.moviePlayback = true
+	e.movieStart = e.frames
+	e.updateMovie()
+	return nil
+}
+
+// MoviePlaying reports whether a movie is being played back.
+func (e *Emulator) MoviePlaying() bool {
+	return e.movie != nil && e.moviePlayback && e.frames-e.movieStart < uint64(len(e.movie.Inputs))
+}
+
+// StopMovie stops recording or playing back the current movie.
+func (e *Emulator) StopMovie() {
+	e.movie = nil
+}
+
+// updateMovie records the input of the completed frame or sets the input
+// of the next frame from the movie. Frames are counted from the start of the
+// movie, so rewinding truncates a recording and moves the playback back.
+func (e *Emulator) updateMovie() {
+	pos := e.frames - e.movieStart
+	if !e.moviePlayback {
+		if pos == 0 {
+			return
+		}
+		if pos-1 < uint64(len(e.movie.Inputs)) {
+			e.movie.Inputs = e.movie.Inputs[:pos-1]
+		}
+		e.movie.Inputs = append(e.movie.Inputs, e.joypad.pressed)
+		return
+	}
+	if pos < uint64(len(e.movie.Inputs)) {
+		e.joypad.pressed = e.movie.Inputs[pos]
+	}
+}
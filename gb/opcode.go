@@ -0,0 +1,276 @@
+package gb
+
+const opCodeExt uint16 = 0xCB
+const opCodeDebugTrap uint16 = 0x40 // LD B,B
+
+var instruction = map[uint16]Instruction{
+	0x01:   {"LD BC,nn", 12, LD_BC_nn},
+	0x02:   {"LD (BC),A", 8, LD_pBC_A},
+	0x03:   {"INC BC", 8, INC_BC},
+	0x04:   {"INC B", 4, INC_B},
+	0x05:   {"DEC B", 4, DEC_B},
+	0x06:   {"LD B,n", 8, LD_B_n},
+	0x07:   {"RLCA", 4, RLCA},
+	0x0C:   {"INC C", 4, INC_C},
+	0x0D:   {"DEC C", 4, DEC_C},
+	0x0A:   {"LD A,(BC)", 8, LD_A_pBC},
+	0x0B:   {"DEC BC", 8, DEC_BC},
+	0x0E:   {"LD C,n", 8, LD_C_n},
+	0x0F:   {"RRCA", 4, RRCA},
+	0x11:   {"LD DE,nn", 12, LD_DE_nn},
+	0x12:   {"LD (DE),A", 8, LD_pDE_A},
+	0x13:   {"INC DE", 8, INC_DE},
+	0x14:   {"INC D", 4, INC_D},
+	0x15:   {"DEC D", 4, DEC_D},
+	0x16:   {"LD D,n", 8, LD_D_n},
+	0x17:   {"RLA", 4, RLA},
+	0x18:   {"JP n", 12, JP_n},
+	0x1A:   {"LD A,(DE)", 8, LD_A_pDE},
+	0x1B:   {"DEC DE", 8, DEC_DE},
+	0x1C:   {"INC E", 4, INC_E},
+	0x1D:   {"DEC E", 4, DEC_E},
+	0x1E:   {"LD E,n", 8, LD_E_n},
+	0x1F:   {"RRA", 4, RRA},
+	0x20:   {"JP NZ,n", 8, JP_NZ_n},
+	0x21:   {"LD HL,nn", 12, LD_HL_nn},
+	0x22:   {"LD (HL+),A", 8, LDI_pHL_A},
+	0x23:   {"INC HL", 8, INC_HL},
+	0x24:   {"INC H", 4, INC_H},
+	0x25:   {"DEC H", 4, DEC_H},
+	0x26:   {"LD H,n", 8, LD_H_n},
+	0x28:   {"JP Z,n", 8, JP_Z_n},
+	0x2A:   {"LD A,(HL+)", 8, LDI_A_pHL},
+	0x2B:   {"DEC HL", 8, DEC_HL},
+	0x2C:   {"INC L", 4, INC_L},
+	0x2D:   {"DEC L", 4, DEC_L},
+	0x2E:   {"LD L,n", 8, LD_L_n},
+	0x30:   {"JP NC,n", 8, JP_NC_n},
+	0x31:   {"LD SP,nn", 12, LD_SP_nn},
+	0x32:   {"LD (HL-),A", 8, LDD_pHL_A},
+	0x33:   {"INC SP", 8, INC_SP},
+	0x34:   {"INC (HL)", 12, INC_pHL},
+	0x35:   {"DEC (HL)", 12, DEC_pHL},
+	0x38:   {"JP C,n", 8, JP_C_n},
+	0x3A:   {"LD A,(HL-)", 8, LDD_A_pHL},
+	0x3B:   {"DEC SP", 8, DEC_SP},
+	0x3C:   {"INC A", 4, INC_A},
+	0x3D:   {"DEC A", 4, DEC_A},
+	0x3E:   {"LD A,n", 8, LD_A_n},
+	0x40:   {"LD B,B", 4, LD_B_B},
+	0x47:   {"LD B,A", 4, LD_B_A},
+	0x4F:   {"LD C,A", 4, LD_C_A},
+	0x57:   {"LD D,A", 4, LD_D_A},
+	0x5F:   {"LD E,A", 4, LD_E_A},
+	0x67:   {"LD H,A", 4, LD_H_A},
+	0x6F:   {"LD L,A", 4, LD_L_A},
+	0x77:   {"LD (HL),A", 8, LD_pHL_A},
+	0x78:   {"LD A,B", 4, LD_A_B},
+	0x79:   {"LD A,C", 4, LD_A_C},
+	0x7A:   {"LD A,D", 4, LD_A_D},
+	0x7B:   {"LD A,E", 4, LD_A_E},
+	0x7C:   {"LD A,H", 4, LD_A_H},
+	0x7D:   {"LD A,L", 4, LD_A_L},
+	0x7E:   {"LD A,(HL)", 8, LD_A_pHL},
+	0x7F:   {"LD A,A", 4, LD_A_A},
+	0xAF:   {"XOR A", 4, XOR_A_A},
+	0xA8:   {"XOR B", 4, XOR_A_B},
+	0xA9:   {"XOR C", 4, XOR_A_C},
+	0xAA:   {"XOR D", 4, XOR_A_D},
+	0xAB:   {"XOR E", 4, XOR_A_E},
+	0xAC:   {"XOR H", 4, XOR_A_H},
+	0xAD:   {"XOR L", 4, XOR_A_L},
+	0xAE:   {"XOR (HL)", 8, XOR_A_pHL},
+	0xB8:   {"CP B", 4, CP_B},
+	0xB9:   {"CP C", 4, CP_C},
+	0xBA:   {"CP D", 4, CP_D},
+	0xBB:   {"CP E", 4, CP_E},
+	0xBC:   {"CP H", 4, CP_H},
+	0xBD:   {"CP L", 4, CP_L},
+	0xBE:   {"CP (HL)", 8, CP_pHL},
+	0xFE:   {"CP n", 8, CP_n},
+	0xBF:   {"CP A", 4, CP_A},
+	0xC0:   {"RET NZ", 8, RET_NZ},
+	0xC1:   {"POP BC", 12, POP_BC},
+	0xC2:   {"JP NZ,nn", 12, JP_NZ_nn},
+	0xC3:   {"JP nn", 16, JP_nn},
+	0xC4:   {"CALL NZ,nn", 12, CALL_NZ_nn},
+	0xC5:   {"PUSH BC", 16, PUSH_BC},
+	0xC8:   {"RET Z", 8, RET_Z},
+	0xC9:   {"RET", 16, RET},
+	0xCA:   {"JP Z,nn", 12, JP_Z_nn},
+	0xCC:   {"CALL Z,nn", 12, CALL_Z_nn},
+	0xCD:   {"CALL nn", 24, CALL_nn},
+	0xD0:   {"RET NC", 8, RET_NC},
+	0xD1:   {"POP DE", 12, POP_DE},
+	0xD2:   {"JP NC,nn", 12, JP_NC_nn},
+	0xD4:   {"CALL NC,nn", 12, CALL_NC_nn},
+	0xD5:   {"PUSH DE", 16, PUSH_DE},
+	0xD8:   {"RET C", 8, RET_C},
+	0xDA:   {"JP C,nn", 12, JP_C_nn},
+	0xDC:   {"CALL C,nn", 12, CALL_C_nn},
+	0xEA:   {"LD (nn),A", 16, LD_pnn_A},
+	0xE0:   {"LD ($FF00+n),A", 12, LD_IO_n_A},
+	0xE1:   {"POP HL", 12, POP_HL},
+	0xE2:   {"LD ($FF00+C),A", 8, LD_IO_C_A},
+	0xE5:   {"PUSH HL", 16, PUSH_HL},
+	0xE9:   {"JP (HL)", 4, JP_pHL},
+	0xEE:   {"XOR n", 8, XOR_A_n},
+	0xF0:   {"LD A,($FF00+n)", 12, LD_A_IO_n},
+	0xF1:   {"POP AF", 12, POP_AF},
+	0xFA:   {"LD A,(nn)", 16, LD_A_pnn},
+	0xF2:   {"LD A,($FF00+C)", 8, LD_A_IO_C},
+	0xF5:   {"PUSH AF", 16, PUSH_AF},
+	0xCB00: {"RLC B", 8, RLC_B},
+	0xCB01: {"RLC C", 8, RLC_C},
+	0xCB02: {"RLC D", 8, RLC_D},
+	0xCB03: {"RLC E", 8, RLC_E},
+	0xCB04: {"RLC H", 8, RLC_H},
+	0xCB05: {"RLC L", 8, RLC_L},
+	0xCB06: {"RLC (HL)", 16, RLC_pHL},
+	0xCB07: {"RLC A", 8, RLC_A},
+	0xCB08: {"RRC B", 8, RRC_B},
+	0xCB09: {"RRC C", 8, RRC_C},
+	0xCB0A: {"RRC D", 8, RRC_D},
+	0xCB0B: {"RRC E", 8, RRC_E},
+	0xCB0C: {"RRC H", 8, RRC_H},
+	0xCB0D: {"RRC L", 8, RRC_L},
+	0xCB0E: {"RRC (HL)", 16, RRC_pHL},
+	0xCB0F: {"RRC A", 8, RRC_A},
+	0xCB10: {"RL B", 8, RL_B},
+	0xCB11: {"RL C", 8, RL_C},
+	0xCB12: {"RL D", 8, RL_D},
+	0xCB13: {"RL E", 8, RL_E},
+	0xCB14: {"RL H", 8, RL_H},
+	0xCB15: {"RL L", 8, RL_L},
+	0xCB16: {"RL (HL)", 16, RL_pHL},
+	0xCB17: {"RL A", 8, RL_A},
+	0xCB18: {"RR B", 8, RR_B},
+	0xCB19: {"RR C", 8, RR_C},
+	0xCB1A: {"RR D", 8, RR_D},
+	0xCB1B: {"RR E", 8, RR_E},
+	0xCB1C: {"RR H", 8, RR_H},
+	0xCB1D: {"RR L", 8, RR_L},
+	0xCB1E: {"RR (HL)", 16, RR_pHL},
+	0xCB1F: {"RR A", 8, RR_A},
+	0xCB40: {"BIT 0,B", 8, BIT_0_B},
+	0xCB41: {"BIT 0,C", 8, BIT_0_C},
+	0xCB42: {"BIT 0,D", 8, BIT_0_D},
+	0xCB43: {"BIT 0,E", 8, BIT_0_E},
+	0xCB44: {"BIT 0,H", 8, BIT_0_H},
+	0xCB45: {"BIT 0,L", 8, BIT_0_L},
+	0xCB46: {"BIT 0,(HL)", 12, BIT_0_pHL},
+	0xCB47: {"BIT_0,A", 8, BIT_0_A},
+	0xCB48: {"BIT_1,B", 8, BIT_1_B},
+	0xCB49: {"BIT_1,C", 8, BIT_1_C},
+	0xCB4A: {"BIT_1,D", 8, BIT_1_D},
+	0xCB4B: {"BIT_1,E", 8, BIT_1_E},
+	0xCB4C: {"BIT_1,H", 8, BIT_1_H},
+	0xCB4D: {"BIT_1,L", 8, BIT_1_L},
+	0xCB4E: {"BIT_1,(HL)", 12, BIT_1_pHL},
+	0xCB4F: {"BIT_1,A", 8, BIT_1_A},
+	0xCB50: {"BIT_2,B", 8, BIT_2_B},
+	0xCB51: {"BIT_2,C", 8, BIT_2_C},
+	0xCB52: {"BIT_2,D", 8, BIT_2_D},
+	0xCB53: {"BIT_2,E", 8, BIT_2_E},
+	0xCB54: {"BIT_2,H", 8, BIT_2_H},
+	0xCB55: {"BIT_2,L", 8, BIT_2_L},
+	0xCB56: {"BIT_2,(HL)", 12, BIT_2_pHL},
+	0xCB57: {"BIT_2,A", 8, BIT_2_A},
+	0xCB58: {"BIT_3,B", 8, BIT_3_B},
+	0xCB59: {"BIT_3,C", 8, BIT_3_C},
+	0xCB5A: {"BIT_3,D", 8, BIT_3_D},
+	0xCB5B: {"BIT_3,E", 8, BIT_3_E},
+	0xCB5C: {"BIT_3,H", 8, BIT_3_H},
+	0xCB5D: {"BIT_3,L", 8, BIT_3_L},
+	0xCB5E: {"BIT_3,(HL)", 12, BIT_3_pHL},
+	0xCB5F: {"BIT_3,A", 8, BIT_3_A},
+	0xCB60: {"BIT_4,B", 8, BIT_4_B},
+	0xCB61: {"BIT_4,C", 8, BIT_4_C},
+	0xCB62: {"BIT_4,D", 8, BIT_4_D},
+	0xCB63: {"BIT_4,E", 8, BIT_4_E},
+	0xCB64: {"BIT_4,H", 8, BIT_4_H},
+	0xCB65: {"BIT_4,L", 8, BIT_4_L},
+	0xCB66: {"BIT_4,(HL)", 12, BIT_4_pHL},
+	0xCB67: {"BIT_4,A", 8, BIT_4_A},
+	0xCB68: {"BIT_5,B", 8, BIT_5_B},
+	0xCB69: {"BIT_5,C", 8, BIT_5_C},
+	0xCB6A: {"BIT_5,D", 8, BIT_5_D},
+	0xCB6B: {"BIT_5,E", 8, BIT_5_E},
+	0xCB6C: {"BIT_5,H", 8, BIT_5_H},
+	0xCB6D: {"BIT_5,L", 8, BIT_5_L},
+	0xCB6E: {"BIT_5,(HL)", 12, BIT_5_pHL},
+	0xCB6F: {"BIT_5,A", 8, BIT_5_A},
+	0xCB70: {"BIT_6,B", 8, BIT_6_B},
+	0xCB71: {"BIT_6,C", 8, BIT_6_C},
+	0xCB72: {"BIT_6,D", 8, BIT_6_D},
+	0xCB73: {"BIT_6,E", 8, BIT_6_E},
+	0xCB74: {"BIT_6,H", 8, BIT_6_H},
+	0xCB75: {"BIT_6,L", 8, BIT_6_L},
+	0xCB76: {"BIT_6,(HL)", 12, BIT_6_pHL},
+	0xCB77: {"BIT_6,A", 8, BIT_6_A},
+	0xCB78: {"BIT_7,B", 8, BIT_7_B},
+	0xCB79: {"BIT_7,C", 8, BIT_7_C},
+	0xCB7A: {"BIT_7,D", 8, BIT_7_D},
+	0xCB7B: {"BIT_7,E", 8, BIT_7_E},
+	0xCB7C: {"BIT_7,H", 8, BIT_7_H},
+	0xCB7D: {"BIT_7,L", 8, BIT_7_L},
+	0xCB7E: {"BIT_7,(HL)", 12, BIT_7_pHL},
+	0xCB7F: {"BIT_7,A", 8, BIT_7_A},
+}
+
+// branchCycles holds the number of additional clock cycles a conditional
+// jump, call or return takes if its condition is met.
+var branchCycles = map[uint16]uint{
+	0x20: 4,
+	0x28: 4,
+	0x30: 4,
+	0x38: 4,
+	0xC0: 12,
+	0xC2: 4,
+	0xC4: 12,
+	0xC8: 12,
+	0xCA: 4,
+	0xCC: 12,
+	0xD0: 12,
+	0xD2: 4,
+	0xD4: 12,
+	0xD8: 12,
+	0xDA: 4,
+	0xDC: 12,
+}
+
+// conditionMet evaluates the condition encoded in bits 3 and 4 of a
+// conditional jump, call or return op code (NZ, Z, NC, C).
+func conditionMet(opCode uint16, reg *Registers) bool {
+	switch (opCode >> 3) & 0x03 {
+	case 0:
+		return !reg.IsFlagSet(zeroFlag)
+	case 1:
+		return reg.IsFlagSet(zeroFlag)
+	case 2:
+		return !reg.IsFlagSet(carryFlag)
+	default:
+		return reg.IsFlagSet(carryFlag)
+	}
+}
+
+// isCall reports whether opCode calls a subroutine (CALL, CALL cc or RST)
+// and returns the length of the instruction.
+func isCall(opCode uint8) (bool, uint16) {
+	switch opCode {
+	case 0xCD, 0xC4, 0xCC, 0xD4, 0xDC:
+		return true, 3
+	case 0xC7, 0xCF, 0xD7, 0xDF, 0xE7, 0xEF, 0xF7, 0xFF:
+		return true, 1
+	}
+	return false, 0
+}
+
+// isReturn reports whether opCode returns from a subroutine (RET, RET cc or RETI).
+func isReturn(opCode uint8) bool {
+	switch opCode {
+	case 0xC9, 0xD9, 0xC0, 0xC8, 0xD0, 0xD8:
+		return true
+	}
+	return false
+}
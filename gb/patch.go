@@ -0,0 +1,171 @@
+package gb
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// setRegister sets a register, register pair or flag to the value of an expression.
+func (d *Debugger) setRegister(name, value string) {
+	e, err := d.parseExpr(value)
+	if err != nil {
+		fmt.Printf("Invalid value: %v\n", err)
+		return
+	}
+	val, err := e.eval(d.exprEnv())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	reg := d.reg
+	name = strings.ToUpper(name)
+	if flag, ok := exprFlags[name]; ok {
+		reg.SetFlags(flag, val != 0)
+		return
+	}
+	switch name {
+	case "A":
+		reg.A = uint8(val)
+	case "F":
+		// The lower nibble of F is always 0.
+		reg.F = uint8(val) & 0xF0
+	case "B":
+		reg.B = uint8(val)
+	case "C":
+		reg.C = uint8(val)
+	case "D":
+		reg.D = uint8(val)
+	case "E":
+		reg.E = uint8(val)
+	case "H":
+		reg.H = uint8(val)
+	case "L":
+		reg.L = uint8(val)
+	case "AF":
+		reg.SetAF(uint16(val) & 0xFFF0)
+	case "BC":
+		reg.SetBC(uint16(val))
+	case "DE":
+		reg.SetDE(uint16(val))
+	case "HL":
+		reg.SetHL(uint16(val))
+	case "SP":
+		reg.SP = uint16(val)
+	case "PC":
+		reg.PC = uint16(val)
+	default:
+		fmt.Printf("Unknown register: %v\n", name)
+	}
+}
+
+// writeMemory writes data starting at loc. Unless rom is set the bytes are
+// written through the bus like the CPU would, so writes to the ROM area set
+// the MBC registers. With rom set they patch the cartridge ROM (or RAM) in
+// the bank of loc or, without bank, in the mapped one. Data that does not
+// fit below 0x10000 is rejected. It returns whether all bytes were written.
+func (d *Debugger) writeMemory(loc location, data []byte, rom bool) bool {
+	if len(data) > 0x10000-int(loc.addr) {
+		fmt.Printf("Error: %v bytes do not fit at %v\n", len(data), loc)
+		return false
+	}
+
+	if !rom {
+		for i, b := range data {
+			d.mem.Write8(loc.addr+uint16(i), b)
+		}
+		if err := d.fault(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		return true
+	}
+
+	banker, ok := d.mem.(Banker)
+	if !ok {
+		fmt.Println("The memory has no banks to patch")
+		return false
+	}
+	for i, b := range data {
+		addr := loc.addr + uint16(i)
+		bank := loc.bank
+		if bank < 0 {
+			bank = banker.Bank(addr)
+		}
+		if !banker.WriteBank(bank, addr, b) {
+			fmt.Printf("Error: can not patch %v\n", location{bank, addr})
+			return false
+		}
+	}
+	return true
+}
+
+// parseBytes parses hexadecimal bytes separated by spaces.
+func parseBytes(s string) ([]byte, error) {
+	var data []byte
+	for _, field := range strings.Fields(s) {
+		b, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte %q", field)
+		}
+		data = append(data, uint8(b))
+	}
+	return data, nil
+}
+
+func (d *Debugger) setMemory(rom bool, addr, bytes string) {
+	loc, err := d.parseLocation(addr)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	data, err := parseBytes(bytes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	d.writeMemory(loc, data, rom)
+}
+
+func (d *Debugger) fill(rom bool, addr, length, value string) {
+	loc, err := d.parseLocation(addr)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	n, err := strconv.ParseUint(length, 16, 32)
+	if err != nil || n > 0x10000 {
+		fmt.Printf("Invalid length: %v\n", length)
+		return
+	}
+	b, err := strconv.ParseUint(value, 16, 8)
+	if err != nil {
+		fmt.Printf("Invalid byte: %v\n", value)
+		return
+	}
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = uint8(b)
+	}
+	d.writeMemory(loc, data, rom)
+}
+
+// loadFile writes the content of a file to memory.
+func (d *Debugger) loadFile(rom bool, addr, path string) {
+	loc, err := d.parseLocation(addr)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if !d.writeMemory(loc, data, rom) {
+		return
+	}
+	fmt.Printf("Loaded %v bytes to %v\n", len(data), loc)
+}
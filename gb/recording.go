@@ -0,0 +1,234 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// RecordingFormat is the file format of a gameplay recording.
+type RecordingFormat int
+
+const (
+	RecordingGIF RecordingFormat = iota
+	RecordingAPNG
+)
+
+// RecordingFormatFromPath returns the recording format that matches the file
+// extension of path (.gif or .png/.apng).
+func RecordingFormatFromPath(path string) (RecordingFormat, error) {
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, ".gif") {
+		return RecordingGIF, nil
+	}
+	if strings.HasSuffix(lower, ".png") || strings.HasSuffix(lower, ".apng") {
+		return RecordingAPNG, nil
+	}
+	return RecordingGIF, fmt.Errorf("unknown recording format of file %v (use .gif or .png)", path)
+}
+
+// Recorder captures every Nth frame of the emulation into an animated image.
+type Recorder struct {
+	// Active enables capturing. It can be toggled to record only parts of the gameplay.
+	Active bool
+	// First and Last limit the recording to a range of frame numbers.
+	// A Last of 0 means no limit.
+	First uint64
+	Last  uint64
+
+	format  RecordingFormat
+	palette Palette
+	scale   int
+	every   uint64
+	frames  []*image.Paletted
+}
+
+// NewRecorder creates an active recorder that captures every Nth frame.
+func NewRecorder(format RecordingFormat, palette Palette, scale int, every uint) *Recorder {
+	if every < 1 {
+		every = 1
+	}
+	return &Recorder{
+		Active:  true,
+		format:  format,
+		palette: palette,
+		scale:   scale,
+		every:   uint64(every),
+	}
+}
+
+// AddFrame captures the frame with the given number if the recorder is
+// active and the frame number is in range.
+func (r *Recorder) AddFrame(number uint64, frame *Framebuffer) {
+	if !r.Active || number < r.First || (r.Last != 0 && number > r.Last) {
+		return
+	}
+	if (number-r.First)%r.every != 0 {
+		return
+	}
+	r.frames = append(r.frames, frame.Image(r.palette, r.scale))
+}
+
+// Done reports whether the last frame of the range has been passed.
+func (r *Recorder) Done(number uint64) bool {
+	return r.Last != 0 && number >= r.Last
+}
+
+// FrameCount returns the number of captured frames.
+func (r *Recorder) FrameCount() int {
+	return len(r.frames)
+}
+
+// Encode writes the captured frames as animated image.
+func (r *Recorder) Encode(w io.Writer) error {
+	if len(r.frames) == 0 {
+		return errors.New("no frames recorded")
+	}
+	if r.format == RecordingAPNG {
+		return r.encodeAPNG(w)
+	}
+	return r.encodeGIF(w)
+}
+
+// delays returns the display time of each captured frame in units of
+// 1/unitsPerSecond seconds. Rounding errors are carried over to the next frame
+// so the playback speed matches the DMG (about 16.74ms per frame).
+func (r *Recorder) delays(unitsPerSecond int) []int {
+	delays := make([]int, len(r.frames))
+	frameTime := float64(r.every) * CyclesPerFrame / ClockRate * float64(unitsPerSecond)
+	shown := 0
+	for i := range delays {
+		end := int(math.Round(float64(i+1) * frameTime))
+		delays[i] = end - shown
+		shown = end
+	}
+	return delays
+}
+
+func (r *Recorder) encodeGIF(w io.Writer) error {
+	anim := &gif.GIF{
+		Image: r.frames,
+		Delay: r.delays(100),
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// encodeAPNG writes the frames as animated PNG. Every frame is encoded as
+// regular PNG and its image data is moved into the fdAT chunks of the animation.
+func (r *Recorder) encodeAPNG(w io.Writer) error {
+	const delayDen = 10000
+	delays := r.delays(delayDen)
+
+	var out bytes.Buffer
+	out.WriteString("\x89PNG\r\n\x1a\n")
+	seq := uint32(0)
+
+	for i, frame := range r.frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, frame); err != nil {
+			return err
+		}
+		chunks, err := readPNGChunks(buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			for _, c := range chunks {
+				if c.typ == "IHDR" {
+					writePNGChunk(&out, "IHDR", c.data)
+					actl := make([]byte, 8)
+					binary.BigEndian.PutUint32(actl[0:], uint32(len(r.frames)))
+					binary.BigEndian.PutUint32(actl[4:], 0) // loop forever
+					writePNGChunk(&out, "acTL", actl)
+				} else if c.typ == "PLTE" || c.typ == "tRNS" {
+					writePNGChunk(&out, c.typ, c.data)
+				}
+			}
+		}
+
+		delay := delays[i]
+		if delay > math.MaxUint16 {
+			delay = math.MaxUint16
+		}
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(frame.Rect.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(frame.Rect.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:], uint16(delay))
+		binary.BigEndian.PutUint16(fctl[22:], delayDen)
+		writePNGChunk(&out, "fcTL", fctl)
+		seq += 1
+
+		for _, c := range chunks {
+			if c.typ != "IDAT" {
+				continue
+			}
+			if i == 0 {
+				writePNGChunk(&out, "IDAT", c.data)
+			} else {
+				fdat := make([]byte, 4+len(c.data))
+				binary.BigEndian.PutUint32(fdat, seq)
+				copy(fdat[4:], c.data)
+				writePNGChunk(&out, "fdAT", fdat)
+				seq += 1
+			}
+		}
+	}
+
+	writePNGChunk(&out, "IEND", nil)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readPNGChunks splits an encoded PNG image into its chunks.
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	const signatureLen = 8
+	if len(data) < signatureLen {
+		return nil, errors.New("invalid PNG data")
+	}
+	var chunks []pngChunk
+	for pos := signatureLen; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		typ := string(data[pos+4 : pos+8])
+		end := pos + 8 + length + 4
+		if end > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{typ, data[pos+8 : pos+8+length]})
+		pos = end
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes a chunk with length, type, data and CRC.
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+	w.Write(header[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
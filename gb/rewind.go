@@ -0,0 +1,291 @@
+package gb
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+// Rewinder keeps snapshots of the emulator state to step the emulation back
+// in time. A snapshot is taken every N frames. Only the newest snapshot is
+// kept in full, each older one is stored as compressed XOR delta against its
+// successor. The oldest snapshots are dropped to stay within the memory limit.
+// The buttons held during each frame are recorded as well, so any state
+// between two snapshots can be reached by running from the earlier one.
+type Rewinder struct {
+	every    uint64
+	maxBytes int
+	// snapshots are ordered from the oldest to the newest.
+	snapshots []rewindSnapshot
+	// state is the full state of the newest snapshot.
+	state []byte
+	// size is the memory used by the deltas.
+	size      int
+	replaying bool
+}
+
+type rewindSnapshot struct {
+	frame  uint64
+	cycles uint64
+	// delta is the compressed XOR of this state and the state of the next snapshot.
+	delta []byte
+	// inputs are the buttons held during the frames after the snapshot.
+	inputs []Buttons
+}
+
+// NewRewinder creates a rewinder that takes a snapshot every Nth frame and
+// uses at most maxBytes for them.
+func NewRewinder(every uint, maxBytes int) *Rewinder {
+	if every < 1 {
+		every = 1
+	}
+	return &Rewinder{
+		every:    uint64(every),
+		maxBytes: maxBytes,
+	}
+}
+
+func (r *Rewinder) newest() *rewindSnapshot {
+	return &r.snapshots[len(r.snapshots)-1]
+}
+
+// frameCompleted records the input of the frame that was just completed and
+// takes a snapshot if one is due.
+func (r *Rewinder) frameCompleted(e *Emulator) {
+	if len(r.snapshots) > 0 {
+		s := r.newest()
+		s.inputs = append(s.inputs, e.joypad.pressed)
+		if e.frames-s.frame < r.every {
+			return
+		}
+	}
+	r.snapshot(e)
+}
+
+// snapshot takes a snapshot of the current state.
+func (r *Rewinder) snapshot(e *Emulator) {
+	var buf bytes.Buffer
+	if err := e.SaveState(&buf); err != nil {
+		return
+	}
+	state := buf.Bytes()
+	if len(r.snapshots) > 0 {
+		s := r.newest()
+		s.delta = compressDelta(r.state, state)
+		r.size += len(s.delta)
+	}
+	r.snapshots = append(r.snapshots, rewindSnapshot{frame: e.frames, cycles: e.cycles})
+	r.state = state
+
+	for len(r.snapshots) > 1 && r.size+len(r.state) > r.maxBytes {
+		r.size -= len(r.snapshots[0].delta)
+		r.snapshots[0] = rewindSnapshot{}
+		r.snapshots = r.snapshots[1:]
+	}
+}
+
+// dropNewest discards the newest snapshot and makes its predecessor the newest.
+func (r *Rewinder) dropNewest() {
+	r.snapshots = r.snapshots[:len(r.snapshots)-1]
+	if len(r.snapshots) == 0 {
+		r.state = nil
+		return
+	}
+	s := r.newest()
+	r.state = applyDelta(r.state, s.delta)
+	r.size -= len(s.delta)
+	s.delta = nil
+}
+
+// compressDelta returns the compressed XOR of prev and next.
+func compressDelta(prev, next []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(xorBytes(prev, next))
+	w.Close()
+	return buf.Bytes()
+}
+
+// applyDelta restores the previous state from the next state and their delta.
+func applyDelta(next, delta []byte) []byte {
+	diff, _ := io.ReadAll(flate.NewReader(bytes.NewReader(delta)))
+	return xorBytes(diff, next)
+}
+
+// xorBytes returns a XOR b with the length of a. Missing bytes of b count as 0.
+func xorBytes(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i]
+		if i < len(b) {
+			result[i] ^= b[i]
+		}
+	}
+	return result
+}
+
+// SetRewinder sets the rewinder that keeps the history of the emulation.
+// A nil rewinder disables rewinding.
+func (e *Emulator) SetRewinder(r *Rewinder) {
+	e.rewinder = r
+	if r == nil {
+		e.dbg.reverse = nil
+		return
+	}
+	// Allow to rewind up to the current state.
+	if len(r.snapshots) == 0 {
+		r.snapshot(e)
+	}
+	e.dbg.reverse = func(toBreakpoint bool) error {
+		if toBreakpoint {
+			return e.ReverseContinue(func(pc uint16) bool {
+				return e.dbg.breakpointAt(pc)
+			})
+		}
+		return e.ReverseStep()
+	}
+}
+
+// StepBack rewinds the emulation to the end of the previous frame.
+func (e *Emulator) StepBack() error {
+	if e.rewinder == nil {
+		return errors.New("rewind is not enabled")
+	}
+	if e.frames == 0 {
+		return ErrNoHistory
+	}
+	target := e.frames - 1
+	if err := e.loadSnapshot(func(s *rewindSnapshot) bool { return s.frame <= target }); err != nil {
+		return err
+	}
+	return e.replay(func() bool { return e.frames >= target })
+}
+
+// ReverseStep rewinds the emulation by a single instruction.
+func (e *Emulator) ReverseStep() error {
+	if e.rewinder == nil {
+		return errors.New("rewind is not enabled")
+	}
+	now := e.cycles
+	before := func(s *rewindSnapshot) bool { return s.cycles < now }
+	if err := e.loadSnapshot(before); err != nil {
+		return err
+	}
+	// Count the instructions up to the current one, then run all but the last.
+	steps := 0
+	err := e.replay(func() bool {
+		if e.cycles >= now {
+			return true
+		}
+		steps += 1
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.loadSnapshot(before); err != nil {
+		return err
+	}
+	return e.replay(func() bool {
+		steps -= 1
+		return steps < 1
+	})
+}
+
+// ReverseContinue rewinds the emulation to the last instruction before the
+// current one at which isBreak returns true for PC. Without such an
+// instruction the emulation is rewound as far as possible and ErrNoHistory
+// is returned.
+func (e *Emulator) ReverseContinue(isBreak func(pc uint16) bool) error {
+	if e.rewinder == nil {
+		return errors.New("rewind is not enabled")
+	}
+	now := e.cycles
+	for {
+		before := func(s *rewindSnapshot) bool { return s.cycles < now }
+		if err := e.loadSnapshot(before); err != nil {
+			return err
+		}
+		start := e.cycles
+		hit, found := uint64(0), false
+		err := e.replay(func() bool {
+			if e.cycles >= now {
+				return true
+			}
+			if isBreak(e.cpu.reg.PC) {
+				hit, found = e.cycles, true
+			}
+			return false
+		})
+		if err != nil {
+			return err
+		}
+
+		if found || len(e.rewinder.snapshots) == 1 {
+			if err := e.loadSnapshot(before); err != nil {
+				return err
+			}
+			if !found {
+				return ErrNoHistory
+			}
+			return e.replay(func() bool { return e.cycles >= hit })
+		}
+		now = start
+	}
+}
+
+// loadSnapshot restores the newest snapshot for which ok returns true and
+// discards the newer ones.
+func (e *Emulator) loadSnapshot(ok func(s *rewindSnapshot) bool) error {
+	r := e.rewinder
+	for len(r.snapshots) > 0 && !ok(r.newest()) {
+		r.dropNewest()
+	}
+	if len(r.snapshots) == 0 {
+		return ErrNoHistory
+	}
+	if err := e.LoadState(bytes.NewReader(r.state)); err != nil {
+		return err
+	}
+	if s := r.newest(); len(s.inputs) > 0 {
+		e.joypad.pressed = s.inputs[0]
+	}
+	return nil
+}
+
+// replay runs the emulation from the snapshot loaded last with the recorded
+// input until done returns true. The debugger, the trace and the serial output
+// are disabled meanwhile as this part of the emulation already ran once. The
+// input recorded after the reached frame is discarded.
+func (e *Emulator) replay(done func() bool) error {
+	r := e.rewinder
+	s := r.newest()
+	enabled, trace, out := e.dbg.Enabled, e.cpu.trace, e.serial.out
+	e.dbg.Enabled = false
+	e.cpu.trace = false
+	e.serial.out = nil
+	r.replaying = true
+	defer func() {
+		e.dbg.Enabled = enabled
+		e.cpu.trace = trace
+		e.serial.out = out
+		r.replaying = false
+	}()
+
+	for !done() {
+		frame := e.frames
+		if err := e.StepInstruction(); err != nil {
+			return err
+		}
+		if e.frames != frame {
+			if i := e.frames - s.frame; i < uint64(len(s.inputs)) {
+				e.joypad.pressed = s.inputs[i]
+			}
+		}
+	}
+	if n := e.frames - s.frame; n < uint64(len(s.inputs)) {
+		s.inputs = s.inputs[:n]
+	}
+	return nil
+}
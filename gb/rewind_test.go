@@ -0,0 +1,70 @@
+package gb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next []byte
+	}{
+		{"equal", []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"changed", []byte{1, 2, 3}, []byte{1, 0xFF, 3}},
+		{"next shorter", []byte{1, 2, 3, 4}, []byte{1, 2}},
+		{"next longer", []byte{1, 2}, []byte{1, 2, 3, 4}},
+		{"empty", []byte{}, []byte{5, 6}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta := compressDelta(test.prev, test.next)
+			if got := applyDelta(test.next, delta); !bytes.Equal(got, test.prev) {
+				t.Errorf("got %v, want %v", got, test.prev)
+			}
+		})
+	}
+}
+
+func TestRewind(t *testing.T) {
+	emu, err := NewEmulator(nil, callTestROM())
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	emu.SetRewinder(NewRewinder(1, 1<<20))
+
+	type point struct {
+		reg    Registers
+		cycles uint64
+	}
+	var frames []point
+	for i := 0; i < 4; i += 1 {
+		if err := emu.RunFrame(); err != nil {
+			t.Fatalf("Could not run frame: %v", err)
+		}
+		frames = append(frames, point{emu.Registers(), emu.Cycles()})
+	}
+	beforeStep := point{emu.Registers(), emu.Cycles()}
+	if err := emu.StepInstruction(); err != nil {
+		t.Fatalf("Could not step: %v", err)
+	}
+
+	if err := emu.ReverseStep(); err != nil {
+		t.Fatalf("Could not reverse step: %v", err)
+	}
+	if got := (point{emu.Registers(), emu.Cycles()}); got != beforeStep {
+		t.Errorf("after reverse step: got %v, want %v", got, beforeStep)
+	}
+
+	// Each step back restores the state at the end of the previous frame,
+	// which needs the deltas of the older snapshots.
+	for i := len(frames) - 2; i >= 0; i -= 1 {
+		if err := emu.StepBack(); err != nil {
+			t.Fatalf("Could not step back to frame %v: %v", i+1, err)
+		}
+		if got := (point{emu.Registers(), emu.Cycles()}); got != frames[i] {
+			t.Errorf("frame %v: got %v, want %v", i+1, got, frames[i])
+		}
+	}
+}
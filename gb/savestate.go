@@ -0,0 +1,400 @@
+package gb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// A save state starts with a header (magic, format version and the CRC-32 of
+// the cartridge ROM) followed by sections. Each section has a four character
+// id and a length. The fields of a section are little endian and only ever
+// appended, so a state saved by an older version loads with the new fields
+// keeping their current values. Unknown sections are skipped.
+const stateMagic = "GBSS"
+const stateVersion = 1
+
+// stateSection serializes the state of one component.
+type stateSection struct {
+	id   string
+	save func(e *stateEncoder)
+	load func(d *stateDecoder)
+}
+
+func (e *Emulator) stateSections() []stateSection {
+	return []stateSection{
+		{"EMU ", e.saveState, e.loadState},
+		{"CPU ", e.cpu.saveState, e.cpu.loadState},
+		{"MEM ", e.mem.saveState, e.mem.loadState},
+		{"IO  ", e.mem.io.saveState, e.mem.io.loadState},
+		{"CART", e.cart.saveState, e.cart.loadState},
+		{"JOYP", e.joypad.saveState, e.joypad.loadState},
+		{"SERL", e.serial.saveState, e.serial.loadState},
+	}
+}
+
+// SaveState writes the complete state of the emulated machine to w.
+// The timer, PPU and APU are not emulated beyond their registers, which are
+// part of the I/O section.
+func (e *Emulator) SaveState(w io.Writer) error {
+	var header stateEncoder
+	header.buf.WriteString(stateMagic)
+	header.u16(stateVersion)
+	header.u32(e.cart.romChecksum)
+	if _, err := w.Write(header.buf.Bytes()); err != nil {
+		return err
+	}
+
+	for _, s := range e.stateSections() {
+		var enc stateEncoder
+		s.save(&enc)
+		var head stateEncoder
+		head.buf.WriteString(s.id)
+		head.u32(uint32(enc.buf.Len()))
+		if _, err := w.Write(head.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.Write(enc.buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadState restores a state written by SaveState. The state must have been
+// saved with the same cartridge ROM. If the state is invalid the emulator
+// keeps its current state.
+func (e *Emulator) LoadState(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	sections, err := e.parseState(data)
+	if err != nil {
+		return err
+	}
+
+	var backup bytes.Buffer
+	if err := e.SaveState(&backup); err != nil {
+		return err
+	}
+	if err := e.applyState(sections); err != nil {
+		restore, _ := e.parseState(backup.Bytes())
+		e.applyState(restore)
+		return err
+	}
+	return nil
+}
+
+// parseState checks the header of a state and returns its sections by id.
+func (e *Emulator) parseState(data []byte) (map[string][]byte, error) {
+	d := &stateDecoder{data: data}
+	if len(data) < len(stateMagic) || string(data[:len(stateMagic)]) != stateMagic {
+		return nil, ErrInvalidState{"not a save state"}
+	}
+	d.data = d.data[len(stateMagic):]
+	var version uint16
+	var checksum uint32
+	d.u16(&version)
+	d.u32(&checksum)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if version > stateVersion {
+		return nil, ErrInvalidState{"saved by a newer version"}
+	}
+	if checksum != e.cart.romChecksum {
+		return nil, ErrInvalidState{"saved with a different cartridge ROM"}
+	}
+
+	sections := make(map[string][]byte)
+	for len(d.data) > 0 {
+		if len(d.data) < 8 {
+			return nil, ErrInvalidState{"truncated section header"}
+		}
+		id := string(d.data[:4])
+		length := binary.LittleEndian.Uint32(d.data[4:8])
+		d.data = d.data[8:]
+		if uint64(length) > uint64(len(d.data)) {
+			return nil, ErrInvalidState{"truncated section " + id}
+		}
+		sections[id] = d.data[:length]
+		d.data = d.data[length:]
+	}
+	return sections, nil
+}
+
+func (e *Emulator) applyState(sections map[string][]byte) error {
+	for _, s := range e.stateSections() {
+		data, ok := sections[s.id]
+		if !ok {
+			continue
+		}
+		d := &stateDecoder{data: data}
+		s.load(d)
+		if d.err != nil {
+			return d.err
+		}
+	}
+	return nil
+}
+
+// stateEncoder appends the fields of a section.
+type stateEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *stateEncoder) u8(v uint8) {
+	e.buf.WriteByte(v)
+}
+
+func (e *stateEncoder) bool(v bool) {
+	if v {
+		e.u8(1)
+	} else {
+		e.u8(0)
+	}
+}
+
+func (e *stateEncoder) u16(v uint16) {
+	e.buf.Write(binary.LittleEndian.AppendUint16(nil, v))
+}
+
+func (e *stateEncoder) u32(v uint32) {
+	e.buf.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func (e *stateEncoder) u64(v uint64) {
+	e.buf.Write(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+// bytes writes a length prefixed byte slice.
+func (e *stateEncoder) bytes(v []byte) {
+	e.u32(uint32(len(v)))
+	e.buf.Write(v)
+}
+
+// stateDecoder reads the fields of a section. Fields missing at the end of
+// the section were added after the state was saved and keep their value.
+type stateDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *stateDecoder) next(n int) []byte {
+	if d.err != nil || len(d.data) == 0 {
+		return nil
+	}
+	if len(d.data) < n {
+		d.err = ErrInvalidState{"truncated field"}
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *stateDecoder) u8(v *uint8) {
+	if b := d.next(1); b != nil {
+		*v = b[0]
+	}
+}
+
+func (d *stateDecoder) bool(v *bool) {
+	if b := d.next(1); b != nil {
+		*v = b[0] != 0
+	}
+}
+
+func (d *stateDecoder) u16(v *uint16) {
+	if b := d.next(2); b != nil {
+		*v = binary.LittleEndian.Uint16(b)
+	}
+}
+
+func (d *stateDecoder) u32(v *uint32) {
+	if b := d.next(4); b != nil {
+		*v = binary.LittleEndian.Uint32(b)
+	}
+}
+
+func (d *stateDecoder) u64(v *uint64) {
+	if b := d.next(8); b != nil {
+		*v = binary.LittleEndian.Uint64(b)
+	}
+}
+
+// bytes reads a length prefixed byte slice into v, which must have the same length.
+func (d *stateDecoder) bytes(v []byte) {
+	if len(d.data) == 0 {
+		return
+	}
+	var length uint32
+	d.u32(&length)
+	if d.err == nil && int(length) != len(v) {
+		d.err = ErrInvalidState{"size of memory region does not match"}
+	}
+	if b := d.next(len(v)); b != nil {
+		copy(v, b)
+	}
+}
+
+func (e *Emulator) saveState(enc *stateEncoder) {
+	enc.u64(e.cycles)
+	enc.u32(uint32(e.frameCycles))
+	enc.u64(e.frames)
+	pixels := make([]byte, 0, ScreenWidth*ScreenHeight)
+	for y := range e.frame {
+		pixels = append(pixels, e.frame[y][:]...)
+	}
+	enc.bytes(pixels)
+}
+
+func (e *Emulator) loadState(d *stateDecoder) {
+	frameCycles := uint32(e.frameCycles)
+	d.u64(&e.cycles)
+	d.u32(&frameCycles)
+	d.u64(&e.frames)
+	e.frameCycles = uint(frameCycles)
+	pixels := make([]byte, ScreenWidth*ScreenHeight)
+	for y := range e.frame {
+		copy(pixels[y*ScreenWidth:], e.frame[y][:])
+	}
+	d.bytes(pixels)
+	for y := range e.frame {
+		copy(e.frame[y][:], pixels[y*ScreenWidth:])
+	}
+}
+
+// The CPU section holds the registers and the shadow call stack.
+// IME and HALT are not emulated yet.
+func (c *CPU) saveState(enc *stateEncoder) {
+	enc.u8(c.reg.A)
+	enc.u8(c.reg.F)
+	enc.u8(c.reg.B)
+	enc.u8(c.reg.C)
+	enc.u8(c.reg.D)
+	enc.u8(c.reg.E)
+	enc.u8(c.reg.H)
+	enc.u8(c.reg.L)
+	enc.u16(c.reg.SP)
+	enc.u16(c.reg.PC)
+	enc.bool(c.locked)
+	enc.u16(uint16(len(c.calls.frames)))
+	for _, f := range c.calls.frames {
+		enc.u16(f.Caller)
+		enc.u16(f.Target)
+		enc.u16(f.Return)
+		enc.u16(f.SP)
+		enc.bool(f.Interrupt)
+	}
+}
+
+func (c *CPU) loadState(d *stateDecoder) {
+	d.u8(&c.reg.A)
+	d.u8(&c.reg.F)
+	d.u8(&c.reg.B)
+	d.u8(&c.reg.C)
+	d.u8(&c.reg.D)
+	d.u8(&c.reg.E)
+	d.u8(&c.reg.H)
+	d.u8(&c.reg.L)
+	d.u16(&c.reg.SP)
+	d.u16(&c.reg.PC)
+	d.bool(&c.locked)
+	c.calls.reset()
+	var n uint16
+	d.u16(&n)
+	for i := uint16(0); i < n && d.err == nil; i += 1 {
+		var f CallFrame
+		d.u16(&f.Caller)
+		d.u16(&f.Target)
+		d.u16(&f.Return)
+		d.u16(&f.SP)
+		d.bool(&f.Interrupt)
+		c.calls.push(f)
+	}
+}
+
+func (m *Memory) saveState(enc *stateEncoder) {
+	enc.bool(m.bootROMMapped)
+	enc.bytes(m.vram[:])
+	enc.bytes(m.wram[:])
+	enc.bytes(m.oam[:])
+	enc.bytes(m.hram[:])
+	enc.u8(m.ie)
+}
+
+func (m *Memory) loadState(d *stateDecoder) {
+	d.bool(&m.bootROMMapped)
+	d.bytes(m.vram[:])
+	d.bytes(m.wram[:])
+	d.bytes(m.oam[:])
+	d.bytes(m.hram[:])
+	d.u8(&m.ie)
+}
+
+// The I/O section holds the stored value of every register, which includes
+// the registers of the timer, PPU and APU.
+func (b *ioBus) saveState(enc *stateEncoder) {
+	values := make([]byte, ioMemSize)
+	for i, reg := range b.regs {
+		if reg != nil {
+			values[i] = reg.value
+		}
+	}
+	enc.bytes(values)
+}
+
+func (b *ioBus) loadState(d *stateDecoder) {
+	values := make([]byte, ioMemSize)
+	for i, reg := range b.regs {
+		if reg != nil {
+			values[i] = reg.value
+		}
+	}
+	d.bytes(values)
+	for i, reg := range b.regs {
+		if reg != nil {
+			reg.value = values[i]
+		}
+	}
+}
+
+func (c *Cartridge) saveState(enc *stateEncoder) {
+	enc.bool(c.ramEnabled)
+	enc.u16(c.romBank)
+	enc.u8(c.bank2)
+	enc.u8(c.mode)
+	enc.bytes(c.ram)
+}
+
+func (c *Cartridge) loadState(d *stateDecoder) {
+	d.bool(&c.ramEnabled)
+	d.u16(&c.romBank)
+	d.u8(&c.bank2)
+	d.u8(&c.mode)
+	d.bytes(c.ram)
+}
+
+func (j *Joypad) saveState(enc *stateEncoder) {
+	enc.u8(uint8(j.pressed))
+	enc.u8(j.selected)
+}
+
+func (j *Joypad) loadState(d *stateDecoder) {
+	pressed := uint8(j.pressed)
+	d.u8(&pressed)
+	d.u8(&j.selected)
+	j.pressed = Buttons(pressed)
+}
+
+func (s *Serial) saveState(enc *stateEncoder) {
+	enc.u8(s.sb)
+	enc.u8(s.sc)
+}
+
+func (s *Serial) loadState(d *stateDecoder) {
+	d.u8(&s.sb)
+	d.u8(&s.sc)
+}
@@ -0,0 +1,97 @@
+package gb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	emu, err := NewEmulator(nil, callTestROM())
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	if err := emu.RunCycles(1000); err != nil {
+		t.Fatalf("Could not run: %v", err)
+	}
+	emu.mem.Write8(0xC000, 0x42)
+	var state bytes.Buffer
+	if err := emu.SaveState(&state); err != nil {
+		t.Fatalf("Could not save state: %v", err)
+	}
+	reg, cycles, depth := emu.Registers(), emu.Cycles(), len(emu.CallStack())
+
+	if err := emu.RunCycles(5000); err != nil {
+		t.Fatalf("Could not run: %v", err)
+	}
+	emu.mem.Write8(0xC000, 0x00)
+	// Patching the ROM keeps the cartridge the state belongs to.
+	if !emu.mem.WriteBank(0, 0x0160, 0x00) {
+		t.Fatalf("Could not patch the ROM")
+	}
+
+	if err := emu.LoadState(bytes.NewReader(state.Bytes())); err != nil {
+		t.Fatalf("Could not load state: %v", err)
+	}
+	if got := emu.Registers(); got != reg {
+		t.Errorf("registers: got %v, want %v", got, reg)
+	}
+	if got := emu.Cycles(); got != cycles {
+		t.Errorf("cycles: got %v, want %v", got, cycles)
+	}
+	if got := len(emu.CallStack()); got != depth {
+		t.Errorf("call depth: got %v, want %v", got, depth)
+	}
+	if got := emu.mem.Read8(0xC000); got != 0x42 {
+		t.Errorf("memory at 0xC000: got 0x%02X, want 0x42", got)
+	}
+}
+
+func TestLoadStateRejects(t *testing.T) {
+	emu, err := NewEmulator(nil, callTestROM())
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	var valid bytes.Buffer
+	if err := emu.SaveState(&valid); err != nil {
+		t.Fatalf("Could not save state: %v", err)
+	}
+
+	otherROM := callTestROM()
+	otherROM[0x0134] = 'X'
+	other, err := NewEmulator(nil, otherROM)
+	if err != nil {
+		t.Fatalf("Could not create emulator: %v", err)
+	}
+	var otherState bytes.Buffer
+	if err := other.SaveState(&otherState); err != nil {
+		t.Fatalf("Could not save state: %v", err)
+	}
+
+	newer := append([]byte(nil), valid.Bytes()...)
+	newer[len(stateMagic)] = stateVersion + 1
+
+	tests := []struct {
+		name  string
+		state []byte
+		want  string
+	}{
+		{"no save state", []byte("PNG image"), "not a save state"},
+		{"newer version", newer, "saved by a newer version"},
+		{"other cartridge", otherState.Bytes(), "saved with a different cartridge ROM"},
+		{"truncated", valid.Bytes()[:valid.Len()-1], "truncated section SERL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := emu.LoadState(bytes.NewReader(test.state))
+			var invalid ErrInvalidState
+			if !errors.As(err, &invalid) {
+				t.Fatalf("got error %v, want ErrInvalidState", err)
+			}
+			if invalid.Reason != test.want {
+				t.Errorf("got reason %q, want %q", invalid.Reason, test.want)
+			}
+		})
+	}
+}
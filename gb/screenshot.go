@@ -0,0 +1,83 @@
+package gb
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Palette maps the four shades of the framebuffer (lightest first) to colors.
+type Palette [4]color.RGBA
+
+// PaletteDMG resembles the green LCD of the original Game Boy.
+var PaletteDMG = Palette{
+	{0x9B, 0xBC, 0x0F, 0xFF},
+	{0x8B, 0xAC, 0x0F, 0xFF},
+	{0x30, 0x62, 0x30, 0xFF},
+	{0x0F, 0x38, 0x0F, 0xFF},
+}
+
+// PaletteGrey shows the shades as evenly spaced greys.
+var PaletteGrey = Palette{
+	{0xFF, 0xFF, 0xFF, 0xFF},
+	{0xAA, 0xAA, 0xAA, 0xFF},
+	{0x55, 0x55, 0x55, 0xFF},
+	{0x00, 0x00, 0x00, 0xFF},
+}
+
+// ParsePalette returns the palette with the given name ("dmg", "grey") or a
+// custom palette given as four comma separated hex colors from lightest to
+// darkest, e.g. "#E0F8D0,#88C070,#346856,#081820".
+func ParsePalette(s string) (Palette, error) {
+	switch strings.ToLower(s) {
+	case "dmg", "green":
+		return PaletteDMG, nil
+	case "grey", "gray":
+		return PaletteGrey, nil
+	}
+
+	var p Palette
+	colors := strings.Split(s, ",")
+	if len(colors) != len(p) {
+		return p, fmt.Errorf("palette %q must be dmg, grey or four comma separated colors", s)
+	}
+	for i, c := range colors {
+		c = strings.TrimPrefix(strings.TrimSpace(c), "#")
+		rgb, err := strconv.ParseUint(c, 16, 32)
+		if err != nil || len(c) != 6 {
+			return p, fmt.Errorf("invalid color %q (expected format #RRGGBB)", colors[i])
+		}
+		p[i] = color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xFF}
+	}
+	return p, nil
+}
+
+// Image returns the framebuffer as paletted image, each pixel scaled up
+// to scale x scale pixels.
+func (f *Framebuffer) Image(p Palette, scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	colors := make(color.Palette, len(p))
+	for i := range p {
+		colors[i] = p[i]
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, ScreenWidth*scale, ScreenHeight*scale), colors)
+	for y := 0; y < ScreenHeight*scale; y += 1 {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < ScreenWidth*scale; x += 1 {
+			row[x] = f[y/scale][x/scale] & 0x03
+		}
+	}
+	return img
+}
+
+// WritePNG encodes the framebuffer as PNG image.
+func (f *Framebuffer) WritePNG(w io.Writer, p Palette, scale int) error {
+	return png.Encode(w, f.Image(p, scale))
+}
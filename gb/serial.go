@@ -0,0 +1,57 @@
+package gb
+
+import "io"
+
+const serialTransferStart uint8 = 1 << 7
+const serialInternalClock uint8 = 1 << 0
+
+const interruptSerial uint8 = 1 << 3
+
+// Serial implements the serial port with the registers SB (0xFF01) and SC (0xFF02).
+// No link partner is emulated: a transfer started with the internal clock
+// completes at once, sends SB to the output and receives 0xFF.
+type Serial struct {
+	sb  uint8
+	sc  uint8
+	out io.Writer
+	// requestInterrupt requests the serial interrupt when a transfer is complete.
+	requestInterrupt func(flag uint8)
+}
+
+func NewSerial() *Serial {
+	return &Serial{}
+}
+
+// SetOutput sets the writer that receives every byte sent over the serial port.
+func (s *Serial) SetOutput(w io.Writer) {
+	s.out = w
+}
+
+func (s *Serial) readSB() uint8 {
+	return s.sb
+}
+
+func (s *Serial) writeSB(val uint8) {
+	s.sb = val
+}
+
+func (s *Serial) readSC() uint8 {
+	return s.sc
+}
+
+func (s *Serial) writeSC(val uint8) {
+	s.sc = val
+	if val&serialTransferStart == 0 || val&serialInternalClock == 0 {
+		// Transfers with external clock wait for a link partner forever.
+		return
+	}
+
+	if s.out != nil {
+		s.out.Write([]byte{s.sb})
+	}
+	s.sb = 0xFF
+	s.sc &= ^serialTransferStart
+	if s.requestInterrupt != nil {
+		s.requestInterrupt(interruptSerial)
+	}
+}
@@ -0,0 +1,227 @@
+package gb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sm83Dir contains the JSON test vectors of the SingleStepTests sm83 project
+// (one file per op code, e.g. testdata/sm83/v1/3e.json and testdata/sm83/v1/cb 7c.json).
+const sm83Dir = "testdata/sm83/v1"
+
+// sm83MaxErrors limits the number of failing vectors reported per op code.
+const sm83MaxErrors = 5
+
+type sm83Test struct {
+	Name    string            `json:"name"`
+	Initial sm83State         `json:"initial"`
+	Final   sm83State         `json:"final"`
+	Cycles  []json.RawMessage `json:"cycles"`
+}
+
+type sm83State struct {
+	PC  uint16      `json:"pc"`
+	SP  uint16      `json:"sp"`
+	A   uint8       `json:"a"`
+	B   uint8       `json:"b"`
+	C   uint8       `json:"c"`
+	D   uint8       `json:"d"`
+	E   uint8       `json:"e"`
+	F   uint8       `json:"f"`
+	H   uint8       `json:"h"`
+	L   uint8       `json:"l"`
+	RAM [][2]uint16 `json:"ram"`
+}
+
+// busAccess is a read or write on the bus as recorded by flatBus or given
+// by a test vector.
+type busAccess struct {
+	addr  uint16
+	value uint8
+	write bool
+}
+
+func (a busAccess) String() string {
+	if a.write {
+		return fmt.Sprintf("write 0x%02X to 0x%04X", a.value, a.addr)
+	}
+	return fmt.Sprintf("read 0x%02X from 0x%04X", a.value, a.addr)
+}
+
+// flatBus is 64 KiB of RAM without any memory mapped devices. It records
+// every access.
+type flatBus struct {
+	mem      [0x10000]uint8
+	accesses []busAccess
+}
+
+func (b *flatBus) Read8(addr uint16) uint8 {
+	b.accesses = append(b.accesses, busAccess{addr, b.mem[addr], false})
+	return b.mem[addr]
+}
+
+func (b *flatBus) Write8(addr uint16, val uint8) {
+	b.accesses = append(b.accesses, busAccess{addr, val, true})
+	b.mem[addr] = val
+}
+
+func (b *flatBus) Read16(addr uint16) uint16 {
+	loByte := uint16(b.Read8(addr))
+	hiByte := uint16(b.Read8(addr + 1))
+	return (hiByte << 8) | loByte
+}
+
+func (b *flatBus) Write16(addr uint16, val uint16) {
+	b.Write8(addr, uint8(val))
+	b.Write8(addr+1, uint8(val>>8))
+}
+
+func (b *flatBus) Tick(cycles uint) {
+}
+
+// TestSM83 checks every implemented instruction against the SingleStepTests
+// vectors. Op codes without vector file are skipped.
+//
+// The vectors are generated with the SM83 prefetching the next op code during
+// the last cycle of an instruction: PC starts behind the already fetched op code
+// and the last cycle is the fetch of the next one. The CPU here fetches the
+// op code at the start of an instruction instead, which shifts the accesses by one.
+func TestSM83(t *testing.T) {
+	if _, err := os.Stat(sm83Dir); err != nil {
+		t.Skipf("No test vectors found in %v", sm83Dir)
+	}
+
+	opCodes := make([]uint16, 0, len(instruction))
+	for opCode := range instruction {
+		opCodes = append(opCodes, opCode)
+	}
+	sort.Slice(opCodes, func(i, j int) bool {
+		return opCodes[i] < opCodes[j]
+	})
+
+	for _, opCode := range opCodes {
+		opCode := opCode
+		instr := instruction[opCode]
+		t.Run(fmt.Sprintf("%04X %v", opCode, instr.Name), func(t *testing.T) {
+			fileName := fmt.Sprintf("%02x.json", opCode)
+			if opCode > 0xFF {
+				fileName = fmt.Sprintf("cb %02x.json", opCode&0xFF)
+			}
+			data, err := os.ReadFile(filepath.Join(sm83Dir, fileName))
+			if err != nil {
+				t.Skipf("No test vectors: %v", err)
+			}
+			var tests []sm83Test
+			if err := json.Unmarshal(data, &tests); err != nil {
+				t.Fatalf("Could not parse %v: %v", fileName, err)
+			}
+
+			failures := 0
+			for _, test := range tests {
+				if msg := runSM83Test(&test); msg != "" {
+					t.Errorf("%v: %v", test.Name, msg)
+					failures += 1
+					if failures == sm83MaxErrors {
+						t.Fatalf("Too many errors, skipping the remaining vectors")
+					}
+				}
+			}
+		})
+	}
+}
+
+// runSM83Test executes a single test vector and returns a description of
+// the first mismatch or "" if the result is correct.
+func runSM83Test(test *sm83Test) string {
+	bus := &flatBus{}
+	for _, entry := range test.Initial.RAM {
+		bus.mem[entry[0]] = uint8(entry[1])
+	}
+	cpu := NewCPU(bus)
+	cpu.reg = test.Initial.registers()
+	// Start at the op code the vector assumes to be fetched already.
+	cpu.reg.PC -= 1
+
+	cycles, err := cpu.Step()
+	if err != nil {
+		return err.Error()
+	}
+
+	want := test.Final.registers()
+	got := cpu.reg
+	// Account for the prefetch of the next op code.
+	got.PC += 1
+	if got != want {
+		return fmt.Sprintf("registers: got %v, want %v", formatSM83Registers(got), formatSM83Registers(want))
+	}
+	for _, entry := range test.Final.RAM {
+		if bus.mem[entry[0]] != uint8(entry[1]) {
+			return fmt.Sprintf("memory at 0x%04X: got 0x%02X, want 0x%02X",
+				entry[0], bus.mem[entry[0]], entry[1])
+		}
+	}
+
+	if int(cycles) != len(test.Cycles)*4 {
+		return fmt.Sprintf("cycles: got %v, want %v", cycles, len(test.Cycles)*4)
+	}
+	wantAccesses, err := test.accesses()
+	if err != nil {
+		return err.Error()
+	}
+	// The first read is the op code fetch of this instruction.
+	gotAccesses := bus.accesses[1:]
+	if len(gotAccesses) != len(wantAccesses) {
+		return fmt.Sprintf("bus accesses: got %v, want %v", gotAccesses, wantAccesses)
+	}
+	for i := range wantAccesses {
+		if gotAccesses[i] != wantAccesses[i] {
+			return fmt.Sprintf("bus access %v: got %v, want %v", i, gotAccesses[i], wantAccesses[i])
+		}
+	}
+	return ""
+}
+
+func (s *sm83State) registers() Registers {
+	return Registers{
+		A: s.A, B: s.B, C: s.C, D: s.D, E: s.E, F: s.F, H: s.H, L: s.L,
+		SP: s.SP, PC: s.PC,
+	}
+}
+
+// accesses returns the reads and writes of the M-cycles of the vector without
+// internal cycles and without the prefetch of the next op code in the last cycle.
+func (test *sm83Test) accesses() ([]busAccess, error) {
+	var accesses []busAccess
+	for i, raw := range test.Cycles {
+		if i == len(test.Cycles)-1 {
+			break
+		}
+		var cycle []interface{}
+		if err := json.Unmarshal(raw, &cycle); err != nil {
+			return nil, fmt.Errorf("invalid cycle %v: %v", i, err)
+		}
+		if len(cycle) != 3 {
+			// null: internal cycle without bus access
+			continue
+		}
+		addr, _ := cycle[0].(float64)
+		value, _ := cycle[1].(float64)
+		pins, _ := cycle[2].(string)
+		if strings.Contains(pins, "r") {
+			accesses = append(accesses, busAccess{uint16(addr), uint8(value), false})
+		} else if strings.Contains(pins, "w") {
+			accesses = append(accesses, busAccess{uint16(addr), uint8(value), true})
+		}
+	}
+	return accesses, nil
+}
+
+func formatSM83Registers(r Registers) string {
+	return fmt.Sprintf("AF=%04X BC=%04X DE=%04X HL=%04X SP=%04X PC=%04X",
+		r.AF(), r.BC(), r.DE(), r.HL(), r.SP, r.PC)
+}
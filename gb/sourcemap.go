@@ -0,0 +1,97 @@
+package gb
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// sourceMap maps lines of RGBDS assembler sources to symbols. Symbol files
+// have no line information, so only the lines of labels map to addresses.
+type sourceMap struct {
+	// labels are the labels defined in each source file in order of lines.
+	labels map[string][]sourceLabel
+	// paths are the source files in the order they were first loaded.
+	paths []string
+}
+
+// sourceLabel is a label defined in a source file. Local labels have the
+// full name, like Main.loop.
+type sourceLabel struct {
+	name string
+	line int
+}
+
+var labelPattern = regexp.MustCompile(`^\s*(\.?[A-Za-z_][\w.@#$]*)::?`)
+
+func newSourceMap() *sourceMap {
+	return &sourceMap{labels: make(map[string][]sourceLabel)}
+}
+
+// load reads the labels of a source file. Files are read again on every
+// call as they may have changed.
+func (m *sourceMap) load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var labels []sourceLabel
+	global := ""
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line += 1 {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		matches := labelPattern.FindStringSubmatch(text)
+		if matches == nil {
+			continue
+		}
+		name := matches[1]
+		if strings.HasPrefix(name, ".") {
+			name = global + name
+		} else if i := strings.IndexByte(name, '.'); i >= 0 {
+			global = name[:i]
+		} else {
+			global = name
+		}
+		labels = append(labels, sourceLabel{name, line})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if _, ok := m.labels[path]; !ok {
+		m.paths = append(m.paths, path)
+	}
+	m.labels[path] = labels
+	return nil
+}
+
+// symbolAt returns the symbol of the label defined in line of the source
+// file at path.
+func (m *sourceMap) symbolAt(symbols *Symbols, path string, line int) (Symbol, bool) {
+	for _, label := range m.labels[path] {
+		if label.line != line {
+			continue
+		}
+		// Macros and constants look like labels but have no symbol.
+		return symbols.Lookup(label.name)
+	}
+	return Symbol{}, false
+}
+
+// line returns the source file and line a label is defined in. If more than
+// one file defines it the one loaded first wins.
+func (m *sourceMap) line(name string) (string, int, bool) {
+	for _, path := range m.paths {
+		for _, label := range m.labels[path] {
+			if label.name == name {
+				return path, label.line, true
+			}
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,70 @@
+package gb
+
+import "fmt"
+
+// next steps over a call by running until it returns. Other instructions
+// are stepped into.
+func (d *Debugger) next() {
+	call, length := isCall(d.mem.Read8(d.reg.PC))
+	if !call {
+		d.stepMode = true
+		return
+	}
+	ret := d.reg.PC + length
+	sp := d.reg.SP
+	d.stepMode = false
+	d.stop = func() bool {
+		// Recursive calls reach the return address with a lower SP.
+		return d.reg.PC == ret && d.reg.SP >= sp
+	}
+}
+
+// finish runs until the current subroutine returns, which is the first
+// return that leaves SP above its current value.
+func (d *Debugger) finish() {
+	d.stepMode = false
+	d.stop = d.returnedFrom(d.reg.SP)
+}
+
+// until runs until loc is reached or the current subroutine returns.
+func (d *Debugger) until(loc location) {
+	returned := d.returnedFrom(d.reg.SP)
+	d.stepMode = false
+	d.stop = func() bool {
+		if returned() {
+			return true
+		}
+		return loc.matches(busBank(d.mem, d.reg.PC), d.reg.PC)
+	}
+}
+
+// returnedFrom returns a stop condition that holds after a return
+// instruction left SP above sp.
+func (d *Debugger) returnedFrom(sp uint16) func() bool {
+	// The condition is first checked after the current instruction.
+	returning := isReturn(d.mem.Read8(d.reg.PC))
+	return func() bool {
+		if returning && d.reg.SP > sp {
+			return true
+		}
+		returning = isReturn(d.mem.Read8(d.reg.PC))
+		return false
+	}
+}
+
+// continueN continues and ignores the breakpoint the emulation stopped at
+// n-1 more times.
+func (d *Debugger) continueN(n uint) {
+	d.stepMode = false
+	if n <= 1 {
+		return
+	}
+	bp, ok := d.breaks[d.lastBreak]
+	if !ok {
+		fmt.Println("Not stopped at a breakpoint")
+		return
+	}
+	bp.ignore = n - 1
+	d.breaks[bp.id] = bp
+	fmt.Printf("Will ignore next %v crossings of breakpoint %v\n", n-1, bp.id)
+}
@@ -0,0 +1,190 @@
+package gb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a label with the bank and address it refers to.
+type Symbol struct {
+	Name string
+	Bank int
+	Addr uint16
+}
+
+// Symbols are the labels of a program, e.g. from an RGBDS symbol file.
+type Symbols struct {
+	byName map[string]Symbol
+	// sorted holds the symbols ordered by bank and address.
+	sorted []Symbol
+}
+
+// ParseSymbols reads a symbol file in the format RGBDS (rgblink -n) and
+// other assemblers write: one "bank:addr Label" per line, with the bank
+// and address in hexadecimal and comments starting with ";".
+func ParseSymbols(r io.Reader) (*Symbols, error) {
+	s := &Symbols{byName: make(map[string]Symbol)}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		text := scanner.Text()
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid symbol in line %v: %q", line, scanner.Text())
+		}
+		parts := strings.SplitN(fields[0], ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid address in line %v: %q", line, fields[0])
+		}
+		bank, err := strconv.ParseUint(parts[0], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bank in line %v: %q", line, parts[0])
+		}
+		addr, err := strconv.ParseUint(parts[1], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address in line %v: %q", line, parts[1])
+		}
+		s.add(Symbol{Name: fields[1], Bank: int(bank), Addr: uint16(addr)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	s.sort()
+	return s, nil
+}
+
+var mapBankPattern = regexp.MustCompile(`^\s*\w+ [Bb]ank #(\d+)`)
+var mapSymbolPattern = regexp.MustCompile(`^\s*\$([0-9A-Fa-f]{1,4}) = (\S+)`)
+
+// ParseMap reads the symbols from a map file of rgblink (-m). They are
+// listed below the headings of the banks, like "ROMX bank #1:".
+func ParseMap(r io.Reader) (*Symbols, error) {
+	s := &Symbols{byName: make(map[string]Symbol)}
+	scanner := bufio.NewScanner(r)
+	bank := 0
+	for scanner.Scan() {
+		if matches := mapBankPattern.FindStringSubmatch(scanner.Text()); matches != nil {
+			n, _ := strconv.ParseUint(matches[1], 10, 16)
+			bank = int(n)
+		} else if matches := mapSymbolPattern.FindStringSubmatch(scanner.Text()); matches != nil {
+			addr, _ := strconv.ParseUint(matches[1], 16, 16)
+			s.add(Symbol{Name: matches[2], Bank: bank, Addr: uint16(addr)})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	s.sort()
+	return s, nil
+}
+
+// Merge adds the symbols of other that are not defined yet.
+func (s *Symbols) Merge(other *Symbols) {
+	for _, sym := range other.sorted {
+		if _, ok := s.byName[sym.Name]; !ok {
+			s.add(sym)
+		}
+	}
+	s.sort()
+}
+
+func (s *Symbols) add(sym Symbol) {
+	s.byName[sym.Name] = sym
+	s.sorted = append(s.sorted, sym)
+}
+
+func (s *Symbols) sort() {
+	sort.SliceStable(s.sorted, func(i, j int) bool {
+		a, b := s.sorted[i], s.sorted[j]
+		if a.Bank != b.Bank {
+			return a.Bank < b.Bank
+		}
+		return a.Addr < b.Addr
+	})
+}
+
+// Lookup returns the symbol with the given name.
+func (s *Symbols) Lookup(name string) (Symbol, bool) {
+	if s == nil {
+		return Symbol{}, false
+	}
+	sym, ok := s.byName[name]
+	return sym, ok
+}
+
+// At returns the name of the first symbol at addr in bank.
+func (s *Symbols) At(bank int, addr uint16) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	i := s.search(bank, addr)
+	if i < len(s.sorted) && s.sorted[i].Bank == bank && s.sorted[i].Addr == addr {
+		return s.sorted[i].Name, true
+	}
+	return "", false
+}
+
+// Nearest returns the last symbol at or before addr in bank and the same
+// memory region (ROM0, ROMX, VRAM, SRAM, WRAM, OAM, I/O or HRAM) and the
+// offset of addr from it.
+func (s *Symbols) Nearest(bank int, addr uint16) (Symbol, uint16, bool) {
+	if s == nil {
+		return Symbol{}, 0, false
+	}
+	i := s.search(bank, addr)
+	if i < len(s.sorted) && s.sorted[i].Bank == bank && s.sorted[i].Addr == addr {
+		// Prefer the first of several symbols at the same address.
+		return s.sorted[i], 0, true
+	}
+	if i == 0 {
+		return Symbol{}, 0, false
+	}
+	sym := s.sorted[i-1]
+	if sym.Bank != bank || memoryRegion(sym.Addr) != memoryRegion(addr) {
+		return Symbol{}, 0, false
+	}
+	return sym, addr - sym.Addr, true
+}
+
+// search returns the index of the first symbol at or after addr in bank.
+func (s *Symbols) search(bank int, addr uint16) int {
+	return sort.Search(len(s.sorted), func(i int) bool {
+		sym := s.sorted[i]
+		return sym.Bank > bank || sym.Bank == bank && sym.Addr >= addr
+	})
+}
+
+// memoryRegion returns the start of the region of the memory map addr is in.
+func memoryRegion(addr uint16) uint16 {
+	starts := []uint16{0xFF80, 0xFF00, 0xFE00, 0xC000, 0xA000, 0x8000, 0x4000}
+	for _, start := range starts {
+		if addr >= start {
+			return start
+		}
+	}
+	return 0
+}
+
+// describe returns addr with the nearest symbol, e.g. "0x0152 <Main+2>".
+func (s *Symbols) describe(bank int, addr uint16) string {
+	sym, offset, ok := s.Nearest(bank, addr)
+	switch {
+	case !ok:
+		return fmt.Sprintf("0x%04X", addr)
+	case offset == 0:
+		return fmt.Sprintf("0x%04X <%v>", addr, sym.Name)
+	}
+	return fmt.Sprintf("0x%04X <%v+%v>", addr, sym.Name, offset)
+}
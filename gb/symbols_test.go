@@ -0,0 +1,120 @@
+package gb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSymbols(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Symbol
+		// err is a part of the expected error message.
+		err string
+	}{
+		{
+			name: "sorted by bank and address",
+			input: "; File generated by rgblink\n" +
+				"01:4000 Data\n" +
+				"00:0150 Main ; entry\n" +
+				"\n" +
+				"00:0158 Main.loop\n",
+			want: []Symbol{{"Main", 0, 0x0150}, {"Main.loop", 0, 0x0158}, {"Data", 1, 0x4000}},
+		},
+		{name: "missing name", input: "00:0150\n", err: "invalid symbol in line 1"},
+		{name: "missing bank", input: "0150 Main\n", err: "invalid address in line 1"},
+		{name: "invalid bank", input: "xx:0150 Main\n", err: "invalid bank in line 1"},
+		{name: "invalid address", input: "00:10000 Main\n", err: "invalid address in line 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseSymbols(strings.NewReader(test.input))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Could not parse symbols: %v", err)
+			}
+			if !reflect.DeepEqual(s.sorted, test.want) {
+				t.Errorf("got %v, want %v", s.sorted, test.want)
+			}
+		})
+	}
+}
+
+const testMap = `SUMMARY:
+	ROM0: 19 bytes used / 16365 free
+
+ROM0 bank #0:
+	SECTION: $0150-$0162 ($0013 bytes) ["Main"]
+	         $0150 = Main
+	         $0158 = Main.loop
+	EMPTY: $0163-$3fff ($3e9d bytes)
+
+ROMX bank #2:
+	SECTION: $4000-$4001 ($0002 bytes) ["Data"]
+	         $4000 = Data
+
+WRAM0 bank #0:
+	SECTION: $c000-$c0ff ($0100 bytes) ["Vars"]
+	         $c000 = wBuffer
+`
+
+func TestParseMap(t *testing.T) {
+	s, err := ParseMap(strings.NewReader(testMap))
+	if err != nil {
+		t.Fatalf("Could not parse map: %v", err)
+	}
+	want := []Symbol{{"Main", 0, 0x0150}, {"Main.loop", 0, 0x0158}, {"wBuffer", 0, 0xC000}, {"Data", 2, 0x4000}}
+	if !reflect.DeepEqual(s.sorted, want) {
+		t.Errorf("got %v, want %v", s.sorted, want)
+	}
+
+	// Symbols of the symbol file take precedence over the ones of the map.
+	syms, err := ParseSymbols(strings.NewReader("00:0151 Main\n"))
+	if err != nil {
+		t.Fatalf("Could not parse symbols: %v", err)
+	}
+	syms.Merge(s)
+	if sym, _ := syms.Lookup("Main"); sym.Addr != 0x0151 {
+		t.Errorf("Main: got 0x%04X, want 0x0151", sym.Addr)
+	}
+	if _, ok := syms.Lookup("Data"); !ok {
+		t.Errorf("Data missing after merge")
+	}
+}
+
+func TestNearestSymbol(t *testing.T) {
+	s, err := ParseSymbols(strings.NewReader("00:0150 Main\n00:0158 Main.loop\n02:4000 Data\n00:C000 wBuffer\n"))
+	if err != nil {
+		t.Fatalf("Could not parse symbols: %v", err)
+	}
+
+	tests := []struct {
+		bank int
+		addr uint16
+		want string
+	}{
+		{0, 0x0150, "0x0150 <Main>"},
+		{0, 0x0152, "0x0152 <Main+2>"},
+		{0, 0x0160, "0x0160 <Main.loop+8>"},
+		{0, 0x0100, "0x0100"},
+		// Symbols do not extend into other banks or memory regions.
+		{1, 0x4000, "0x4000"},
+		{2, 0x4001, "0x4001 <Data+1>"},
+		{0, 0x8000, "0x8000"},
+		{0, 0xC010, "0xC010 <wBuffer+16>"},
+	}
+
+	for _, test := range tests {
+		if got := s.describe(test.bank, test.addr); got != test.want {
+			t.Errorf("%v:%04X: got %q, want %q", test.bank, test.addr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package gb
+
+// callTestROM calls a subroutine in an endless loop:
+//
+//	0100: CALL 0x0150
+//	0103: JR 0x0100
+//	0150: LD A,0x01
+//	0152: CALL 0x0160
+//	0155: LD B,B
+//	0156: RET
+//	0160: LD B,B
+//	0161: RET
+func callTestROM() []byte {
+	rom := make([]byte, 2*romBankSize)
+	copy(rom[0x100:], []byte{0xCD, 0x50, 0x01, 0x18, 0xFB})
+	copy(rom[0x150:], []byte{0x3E, 0x01, 0xCD, 0x60, 0x01, 0x40, 0xC9})
+	copy(rom[0x160:], []byte{0x40, 0xC9})
+	return rom
+}
@@ -0,0 +1,7 @@
+package gb
+
+const ScreenWidth = 160
+const ScreenHeight = 144
+
+// Framebuffer holds the shade (0: lightest - 3: darkest) of every pixel on the LCD.
+type Framebuffer [ScreenHeight][ScreenWidth]uint8
@@ -0,0 +1,249 @@
+package gb
+
+import "fmt"
+
+type watchKind uint8
+
+const (
+	watchRead watchKind = 1 << iota
+	watchWrite
+	watchAccess = watchRead | watchWrite
+)
+
+var watchKindNames = map[watchKind]string{
+	watchRead:   "read",
+	watchWrite:  "write",
+	watchAccess: "access",
+}
+
+// Watchpoint stops the emulation after an instruction accessed an address
+// in the range from start to end (inclusive).
+type Watchpoint struct {
+	id uint
+	// bank is the bank the range has to be in or -1 for any bank.
+	bank    int
+	start   uint16
+	end     uint16
+	kind    watchKind
+	enabled bool
+	// change only triggers on writes that change the value.
+	change bool
+}
+
+// watchHit is an access that triggered a watchpoint.
+type watchHit struct {
+	wp    *Watchpoint
+	addr  uint16
+	old   uint8
+	new   uint8
+	write bool
+	// pc is the address of the accessing instruction.
+	pc uint16
+}
+
+func (h watchHit) String() string {
+	if h.write {
+		return fmt.Sprintf("Watchpoint %v: write at 0x%04X by instruction at 0x%04X: 0x%02X -> 0x%02X",
+			h.wp.id, h.addr, h.pc, h.old, h.new)
+	}
+	return fmt.Sprintf("Watchpoint %v: read at 0x%04X by instruction at 0x%04X: 0x%02X",
+		h.wp.id, h.addr, h.pc, h.new)
+}
+
+// watchBus checks every access of the CPU against the watchpoints.
+type watchBus struct {
+	bus    Bus
+	points []*Watchpoint
+	// pc is the address of the instruction being executed.
+	pc uint16
+	// fetched counts the bytes of the instruction read so far and fetchLen
+	// is its length once the op code was read.
+	fetched  int
+	fetchLen int
+	hits     []watchHit
+}
+
+// begin starts the accesses of the instruction at pc.
+func (w *watchBus) begin(pc uint16) {
+	w.pc = pc
+	w.fetched = 0
+	w.fetchLen = 0
+}
+
+func (w *watchBus) Read8(addr uint16) uint8 {
+	val := w.bus.Read8(addr)
+	if len(w.points) == 0 || w.fetch(addr, val) {
+		return val
+	}
+	w.check(addr, val, val, false)
+	return val
+}
+
+// fetch reports whether the read of val at addr fetches the op code or an
+// operand of the current instruction. Instructions read their operands
+// before any other address, so these are the first reads from pc on.
+func (w *watchBus) fetch(addr uint16, val uint8) bool {
+	if w.fetched == 0 && addr == w.pc {
+		w.fetchLen = instrLength(val)
+	}
+	if w.fetched < w.fetchLen && addr == w.pc+uint16(w.fetched) {
+		w.fetched += 1
+		return true
+	}
+	return false
+}
+
+func (w *watchBus) Write8(addr uint16, val uint8) {
+	if !w.watched(addr, watchWrite) {
+		w.bus.Write8(addr, val)
+		return
+	}
+	// The old value is only read for watched addresses, as reads may have
+	// side effects on I/O registers or fault on unmapped ones.
+	old := w.bus.Read8(addr)
+	w.bus.Write8(addr, val)
+	w.check(addr, old, val, true)
+}
+
+func (w *watchBus) Read16(addr uint16) uint16 {
+	loByte := uint16(w.Read8(addr))
+	hiByte := uint16(w.Read8(addr + 1))
+	return (hiByte << 8) | loByte
+}
+
+func (w *watchBus) Write16(addr uint16, val uint16) {
+	w.Write8(addr, uint8(val))
+	w.Write8(addr+1, uint8(val>>8))
+}
+
+func (w *watchBus) Tick(cycles uint) {
+	w.bus.Tick(cycles)
+}
+
+func (w *watchBus) Fault() error {
+	if f, ok := w.bus.(Faulter); ok {
+		return f.Fault()
+	}
+	return nil
+}
+
+func (w *watchBus) Bank(addr uint16) int {
+	return busBank(w.bus, addr)
+}
+
+func (w *watchBus) ReadBank(bank int, addr uint16) uint8 {
+	if b, ok := w.bus.(Banker); ok {
+		return b.ReadBank(bank, addr)
+	}
+	return w.bus.Read8(addr)
+}
+
+func (w *watchBus) WriteBank(bank int, addr uint16, val uint8) bool {
+	if b, ok := w.bus.(Banker); ok {
+		return b.WriteBank(bank, addr, val)
+	}
+	return false
+}
+
+// watched reports whether an enabled watchpoint of the kind covers addr.
+func (w *watchBus) watched(addr uint16, kind watchKind) bool {
+	for _, wp := range w.points {
+		if wp.enabled && wp.kind&kind != 0 && addr >= wp.start && addr <= wp.end {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *watchBus) check(addr uint16, old, new uint8, write bool) {
+	kind := watchRead
+	if write {
+		kind = watchWrite
+	}
+	for _, wp := range w.points {
+		if !wp.enabled || wp.kind&kind == 0 || addr < wp.start || addr > wp.end {
+			continue
+		}
+		if wp.change && (!write || old == new) {
+			continue
+		}
+		if wp.bank >= 0 && busBank(w.bus, addr) != wp.bank {
+			continue
+		}
+		w.hits = append(w.hits, watchHit{wp, addr, old, new, write, w.pc})
+	}
+}
+
+// takeHits returns and clears the hits since the last call.
+func (w *watchBus) takeHits() []watchHit {
+	hits := w.hits
+	w.hits = nil
+	return hits
+}
+
+// addWatchpoint adds a watchpoint and returns its id or 0 if the range is invalid.
+func (d *Debugger) addWatchpoint(kind watchKind, start location, end uint16, change bool) uint {
+	if end < start.addr {
+		fmt.Println("Invalid range: end is before start")
+		return 0
+	}
+	d.breakCount += 1
+	wp := &Watchpoint{
+		id:      d.breakCount,
+		bank:    start.bank,
+		start:   start.addr,
+		end:     end,
+		kind:    kind,
+		enabled: true,
+		change:  change,
+	}
+	d.watch.points = append(d.watch.points, wp)
+	fmt.Printf("Watchpoint %v: %v\n", wp.id, formatWatchpoint(wp))
+	return wp.id
+}
+
+// watchpoint returns the watchpoint with the given id or nil.
+func (d *Debugger) watchpoint(id uint) *Watchpoint {
+	for _, wp := range d.watch.points {
+		if wp.id == id {
+			return wp
+		}
+	}
+	return nil
+}
+
+func (d *Debugger) deleteWatchpoint(id uint) bool {
+	for i, wp := range d.watch.points {
+		if wp.id == id {
+			d.watch.points = append(d.watch.points[:i], d.watch.points[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Debugger) listWatchpoints() {
+	if len(d.watch.points) == 0 {
+		fmt.Println("No Watchpoints")
+		return
+	}
+	fmt.Println("Num\tEnb\tWatch")
+	for _, wp := range d.watch.points {
+		enbStr := "y"
+		if !wp.enabled {
+			enbStr = "n"
+		}
+		fmt.Printf("%v\t%v\t%v\n", wp.id, enbStr, formatWatchpoint(wp))
+	}
+}
+
+func formatWatchpoint(wp *Watchpoint) string {
+	s := fmt.Sprintf("%v %v", watchKindNames[wp.kind], location{wp.bank, wp.start})
+	if wp.end != wp.start {
+		s += fmt.Sprintf("-0x%04X", wp.end)
+	}
+	if wp.change {
+		s += " (on change)"
+	}
+	return s
+}
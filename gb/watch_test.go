@@ -0,0 +1,218 @@
+package gb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatchpoints(t *testing.T) {
+	tests := []struct {
+		name string
+		wp   Watchpoint
+		// access runs the accesses of an instruction.
+		access   func(bus Bus)
+		disabled bool
+		want     []watchHit
+	}{
+		{
+			name:   "write",
+			wp:     Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchWrite},
+			access: func(bus Bus) { bus.Write8(0xC000, 0x12) },
+			want:   []watchHit{{addr: 0xC000, old: 0x00, new: 0x12, write: true}},
+		},
+		{
+			name:   "read ignored by write watchpoint",
+			wp:     Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchWrite},
+			access: func(bus Bus) { bus.Read8(0xC000) },
+		},
+		{
+			name:   "read",
+			wp:     Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchRead},
+			access: func(bus Bus) { bus.Read8(0xC000) },
+			want:   []watchHit{{addr: 0xC000}},
+		},
+		{
+			name: "access",
+			wp:   Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchAccess},
+			access: func(bus Bus) {
+				bus.Write8(0xC000, 0x34)
+				bus.Read8(0xC000)
+			},
+			want: []watchHit{
+				{addr: 0xC000, old: 0x00, new: 0x34, write: true},
+				{addr: 0xC000, old: 0x34, new: 0x34},
+			},
+		},
+		{
+			name: "range",
+			wp:   Watchpoint{bank: -1, start: 0xC000, end: 0xC001, kind: watchWrite},
+			access: func(bus Bus) {
+				bus.Write16(0xC001, 0xABCD)
+				bus.Write8(0xBFFF, 0x01)
+			},
+			want: []watchHit{{addr: 0xC001, old: 0x00, new: 0xCD, write: true}},
+		},
+		{
+			name: "change",
+			wp:   Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchWrite, change: true},
+			access: func(bus Bus) {
+				bus.Write8(0xC000, 0x00)
+				bus.Write8(0xC000, 0x56)
+			},
+			want: []watchHit{{addr: 0xC000, old: 0x00, new: 0x56, write: true}},
+		},
+		{
+			name:   "other bank",
+			wp:     Watchpoint{bank: 1, start: 0xC000, end: 0xC000, kind: watchWrite},
+			access: func(bus Bus) { bus.Write8(0xC000, 0x12) },
+		},
+		{
+			name:     "disabled",
+			wp:       Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchAccess},
+			access:   func(bus Bus) { bus.Write8(0xC000, 0x12) },
+			disabled: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wp := test.wp
+			wp.id = 1
+			wp.enabled = !test.disabled
+			w := &watchBus{bus: &flatBus{}, points: []*Watchpoint{&wp}, pc: 0x0150}
+			test.access(w)
+
+			hits := w.takeHits()
+			if len(hits) != len(test.want) {
+				t.Fatalf("got hits %v, want %v", hits, test.want)
+			}
+			for i, want := range test.want {
+				want.wp = &wp
+				want.pc = 0x0150
+				if hits[i] != want {
+					t.Errorf("hit %v: got %v, want %v", i, hits[i], want)
+				}
+			}
+			if hits := w.takeHits(); len(hits) != 0 {
+				t.Errorf("hits not cleared: %v", hits)
+			}
+		})
+	}
+}
+
+func TestWatchpointAccesses(t *testing.T) {
+	tests := []struct {
+		name string
+		wp   Watchpoint
+		// code is placed at 0x0150 and executed by the CPU.
+		code []byte
+		want []watchHit
+		// accesses is the number of accesses on the bus.
+		accesses int
+	}{
+		{
+			name: "fetches ignored",
+			wp:   Watchpoint{bank: -1, start: 0x0150, end: 0xC000, kind: watchRead},
+			// LD A,(0xC000)
+			code:     []byte{0xFA, 0x00, 0xC0},
+			want:     []watchHit{{addr: 0xC000}},
+			accesses: 4,
+		},
+		{
+			name: "code read by a later instruction",
+			wp:   Watchpoint{bank: -1, start: 0x0152, end: 0x0152, kind: watchRead},
+			// LD HL,0x0152; LD A,(HL)
+			code:     []byte{0x21, 0x52, 0x01, 0x7E},
+			want:     []watchHit{{addr: 0x0152, old: 0x01, new: 0x01}},
+			accesses: 5,
+		},
+		{
+			name: "unwatched write not read",
+			wp:   Watchpoint{bank: -1, start: 0xC000, end: 0xC000, kind: watchWrite},
+			// LD (0xD000),A
+			code:     []byte{0xEA, 0x00, 0xD0},
+			accesses: 4,
+		},
+		{
+			name: "watched write reads the old value",
+			wp:   Watchpoint{bank: -1, start: 0xD000, end: 0xD000, kind: watchWrite},
+			// LD (0xD000),A
+			code:     []byte{0xEA, 0x00, 0xD0},
+			want:     []watchHit{{addr: 0xD000, new: 0x42, write: true}},
+			accesses: 5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := &flatBus{}
+			copy(bus.mem[0x0150:], test.code)
+			wp := test.wp
+			wp.id = 1
+			wp.enabled = true
+			w := &watchBus{bus: bus, points: []*Watchpoint{&wp}}
+			cpu := NewCPU(w)
+			cpu.reg.PC = 0x0150
+			cpu.reg.A = 0x42
+			var hits []watchHit
+			for cpu.reg.PC < 0x0150+uint16(len(test.code)) {
+				w.begin(cpu.reg.PC)
+				if _, err := cpu.Step(); err != nil {
+					t.Fatalf("Could not execute step: %v", err)
+				}
+				hits = append(hits, w.takeHits()...)
+			}
+
+			if len(hits) != len(test.want) {
+				t.Fatalf("got hits %v, want %v", hits, test.want)
+			}
+			for i, want := range test.want {
+				want.wp = &wp
+				want.pc = hits[i].pc
+				if hits[i] != want {
+					t.Errorf("hit %v: got %v, want %v", i, hits[i], want)
+				}
+			}
+			if len(bus.accesses) != test.accesses {
+				t.Errorf("got accesses %v, want %v", bus.accesses, test.accesses)
+			}
+		})
+	}
+}
+
+func TestWatchCommand(t *testing.T) {
+	symbols, err := ParseSymbols(strings.NewReader("00:C000 wBuffer\n00:C0FF wBufferEnd\n"))
+	if err != nil {
+		t.Fatalf("Could not parse symbols: %v", err)
+	}
+
+	tests := []struct {
+		cmd        string
+		start, end uint16
+	}{
+		{"watch C000", 0xC000, 0xC000},
+		{"watch C000 C0FF", 0xC000, 0xC0FF},
+		{"watch 0xC000 0xC0FF", 0xC000, 0xC0FF},
+		{"watch $C000 $C0FF", 0xC000, 0xC0FF},
+		{"watch wBuffer wBufferEnd", 0xC000, 0xC0FF},
+		{"watch 00:C000 00:C0FF", 0xC000, 0xC0FF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.cmd, func(t *testing.T) {
+			d := NewDebugger(&flatBus{}, &Registers{}, &Framebuffer{})
+			d.symbols = symbols
+			matches := watchPattern.FindStringSubmatch(test.cmd)
+			if len(matches) != 5 {
+				t.Fatalf("Command not matched")
+			}
+			d.parseWatchpoint(matches)
+			if len(d.watch.points) != 1 {
+				t.Fatalf("Watchpoint not added")
+			}
+			if wp := d.watch.points[0]; wp.start != test.start || wp.end != test.end {
+				t.Errorf("got 0x%04X-0x%04X, want 0x%04X-0x%04X", wp.start, wp.end, test.start, test.end)
+			}
+		})
+	}
+}